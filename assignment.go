@@ -3,6 +3,7 @@ package toscalib
 import (
 	"fmt"
 	"os"
+	"path"
 	"reflect"
 	"strconv"
 	"strings"
@@ -18,6 +19,12 @@ type Assignment struct {
 
 // UnmarshalYAML converts YAML text to a type
 func (p *Assignment) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	// a null value is no value
+	var v interface{}
+	if err := unmarshal(&v); err == nil && v == nil {
+		return nil
+	}
+
 	var s string
 	if err := unmarshal(&s); err == nil {
 		p.Value = s
@@ -106,247 +113,476 @@ func (p *Assignment) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return fmt.Errorf("Cannot parse Property %v", res)
 }
 
+// MarshalYAML converts the Assignment to its TOSCA notation: the function with
+// its arguments, the constraint clause or the value
+func (p Assignment) MarshalYAML() (interface{}, error) {
+	if p.Function != "" {
+		if len(p.Args) == 1 {
+			if _, isMap := p.Args[0].(map[interface{}]interface{}); !isMap {
+				return map[string]interface{}{p.Function: p.Args[0]}, nil
+			}
+		}
+		return map[string]interface{}{p.Function: p.Args}, nil
+	}
+	if p.Expression.Operator != "" {
+		return p.Expression.MarshalYAML()
+	}
+	return p.Value, nil
+}
+
 func newAssignmentFunc(val interface{}) *Assignment {
 	rval := reflect.ValueOf(val)
 	switch rval.Kind() {
 	case reflect.Map:
-		for k, v := range rval.Interface().(map[interface{}]interface{}) {
-			if !isFunction(k.(string)) {
+		// the map may be decoded from YAML or JSON, or set by SetAttribute
+		for _, k := range rval.MapKeys() {
+			name, ok := k.Interface().(string)
+			if !ok || !isFunction(name) {
 				continue
 			}
+			v := rval.MapIndex(k).Interface()
 			// Convert it to a Assignment
-			rv := reflect.ValueOf(v)
-			switch rv.Kind() {
-			case reflect.String:
-				return &Assignment{Function: k.(string), Args: []interface{}{rv.Interface()}}
-			default:
-				return &Assignment{Function: k.(string), Args: rv.Interface().([]interface{})}
+			if args, ok := v.([]interface{}); ok {
+				return &Assignment{Function: name, Args: args}
 			}
+			return &Assignment{Function: name, Args: []interface{}{v}}
 		}
 	}
 	return nil
 }
 
-func (p *Assignment) lookupValueArg(arg string) interface{} {
-	v := reflect.ValueOf(p.Value)
-	switch v.Kind() {
-	case reflect.Slice:
-		if i, err := strconv.ParseInt(arg, 10, 0); err == nil {
-			return v.Index(int(i)).Interface()
-		}
-	case reflect.Map:
-		return v.MapIndex(reflect.ValueOf(arg)).Interface()
+// EvaluationErrorKind classifies the errors returned by EvaluateE
+type EvaluationErrorKind int
+
+// The kinds of evaluation errors
+const (
+	EvalNotFound     EvaluationErrorKind = iota // A referenced input, node template, property, key... does not exist.
+	EvalBadArity                                // The function has a wrong number of arguments.
+	EvalTypeMismatch                            // An argument or a value is not of the expected type.
+	EvalCycle                                   // The function refers back to itself.
+	EvalFailed                                  // The function could not be carried out, ie. an artifact could not be copied.
+)
+
+func (k EvaluationErrorKind) String() string {
+	switch k {
+	case EvalNotFound:
+		return "not found"
+	case EvalBadArity:
+		return "bad arity"
+	case EvalTypeMismatch:
+		return "type mismatch"
+	case EvalCycle:
+		return "cycle"
+	case EvalFailed:
+		return "failed"
 	}
-	return v.Interface()
+	return fmt.Sprintf("EvaluationErrorKind(%d)", int(k))
 }
 
-func (p *Assignment) evaluate(std *ServiceTemplateDefinition, ctx, arg string) interface{} {
-	if arg != "" {
-		val := p.lookupValueArg(arg)
+// EvaluationError is returned by EvaluateE when a function can't be evaluated
+type EvaluationError struct {
+	Kind     EvaluationErrorKind
+	Function string        // The function that failed, ie. get_property.
+	Args     []interface{} // The arguments of the function.
+	Message  string
+	Chain    []string // The chain of references leading back to one of them, for EvalCycle.
+	Err      error    // The underlying error, if any.
+}
 
-		// handle the scenario when the property value is another
-		// function call.
-		if pa := newAssignmentFunc(val); pa != nil {
-			return pa.Evaluate(std, ctx)
-		}
+func (e *EvaluationError) Error() string {
+	msg := fmt.Sprintf("%s %v: %s", e.Function, e.Args, e.Message)
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	return msg
+}
 
-		if len(p.Args) != 0 {
-			tmp := clone(*p)
-			pa, _ := tmp.(Assignment)
-			pa.Value = val
-			return pa.lookupValueArg(p.Args[0].(string))
-		}
+func (p *Assignment) errorf(kind EvaluationErrorKind, format string, a ...interface{}) error {
+	return &EvaluationError{Kind: kind, Function: p.Function, Args: p.Args, Message: fmt.Sprintf(format, a...)}
+}
 
-		return val
+// checkArity checks the function has between min and max arguments, max
+// being -1 when there is no upper bound
+func (p *Assignment) checkArity(min, max int) error {
+	n := len(p.Args)
+	switch {
+	case min == max && n != min:
+		return p.errorf(EvalBadArity, "expected %d arguments, got %d", min, n)
+	case n < min:
+		return p.errorf(EvalBadArity, "expected at least %d arguments, got %d", min, n)
+	case max != -1 && n > max:
+		return p.errorf(EvalBadArity, "expected at most %d arguments, got %d", max, n)
 	}
-	return p.Evaluate(std, ctx)
+	return nil
 }
 
-func getNTByArgs(std *ServiceTemplateDefinition, ctx string, args []interface{}) (*NodeTemplate, *NodeTemplate) {
-	nt := std.findNodeTemplate(args[0].(string), ctx)
-	if nt == nil {
-		return nil, nil
+func (p *Assignment) stringArg(i int) (string, error) {
+	if s, ok := p.Args[i].(string); ok {
+		return s, nil
 	}
+	return "", p.errorf(EvalTypeMismatch, "argument %d must be a string, got %v", i+1, p.Args[i])
+}
 
-	if r := nt.GetRequirement(args[1].(string)); r != nil {
-		if rnt := std.GetNodeTemplate(r.Node); rnt != nil {
-			return nt, rnt
+// keyArgs returns the arguments from the i-th as names or indexes
+func (p *Assignment) keyArgs(i int) ([]string, error) {
+	var keys []string
+	for ; i < len(p.Args); i++ {
+		switch v := p.Args[i].(type) {
+		case string:
+			keys = append(keys, v)
+		case int:
+			keys = append(keys, strconv.Itoa(v))
+		default:
+			return nil, p.errorf(EvalTypeMismatch, "argument %d must be a name or an index, got %v", i+1, v)
 		}
 	}
+	return keys, nil
+}
+
+// nodeTemplateArg returns the Node Template named by the first argument
+func (p *Assignment) nodeTemplateArg(std *ServiceTemplateDefinition, ctx string) (*NodeTemplate, error) {
+	name, err := p.stringArg(0)
+	if err != nil {
+		return nil, err
+	}
+	nt := std.findNodeTemplate(name, ctx)
+	if nt == nil {
+		return nil, p.errorf(EvalNotFound, "node template %q not found", name)
+	}
 	return nt, nil
 }
 
-func (p *Assignment) evalConcat(std *ServiceTemplateDefinition, ctx string) interface{} {
-	var output string
-	for _, val := range p.Args {
-		switch reflect.TypeOf(val).Kind() {
-		case reflect.String, reflect.Int:
-			output = fmt.Sprintf("%s%s", output, val)
-		case reflect.Map:
-			if pa := newAssignmentFunc(val); pa != nil {
-				if o := pa.Evaluate(std, ctx); o != nil {
-					output = fmt.Sprintf("%s%s", output, o)
-				}
+// lookup evaluates the Assignment a and walks down its value along the keys,
+// the nested values being evaluated when they are functions
+func (p *Assignment) lookup(std *ServiceTemplateDefinition, ctx string, path evalPath, a *Assignment, keys []string) (interface{}, error) {
+	v, err := a.eval(std, ctx, path)
+	for _, key := range keys {
+		if err != nil || v == nil {
+			return nil, err
+		}
+		if v, err = p.lookupKey(v, key); err != nil {
+			return nil, err
+		}
+		if pa := newAssignmentFunc(v); pa != nil {
+			v, err = pa.eval(std, ctx, path)
+		}
+	}
+	return v, err
+}
+
+func (p *Assignment) lookupKey(v interface{}, key string) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, p.errorf(EvalTypeMismatch, "index %q of a list must be an integer", key)
+		}
+		if i < 0 || i >= rv.Len() {
+			return nil, p.errorf(EvalNotFound, "index %d out of range, the list has %d entries", i, rv.Len())
+		}
+		return rv.Index(i).Interface(), nil
+	case reflect.Map:
+		for _, k := range rv.MapKeys() {
+			if fmt.Sprint(k.Interface()) == key {
+				return rv.MapIndex(k).Interface(), nil
 			}
 		}
+		return nil, p.errorf(EvalNotFound, "key %q not found", key)
 	}
-	return output
+	return nil, p.errorf(EvalTypeMismatch, "value %v has no entry %q", v, key)
 }
 
-func (p *Assignment) evalToken(std *ServiceTemplateDefinition, ctx string) interface{} {
+func (p *Assignment) evalConcat(std *ServiceTemplateDefinition, ctx string, path evalPath) (interface{}, error) {
 	var output string
-	var value string
-	var token string
-	for idx, val := range p.Args {
-		switch reflect.TypeOf(val).Kind() {
-		case reflect.String:
-			// the first and second arg must result be of type string or resolve
-			// to be a string.
-			if idx == 0 {
-				value = val.(string)
-			} else if idx == 1 {
-				token = val.(string)
-			}
-		case reflect.Map:
-			// the first input could actually be a lookup for the value
-			if idx == 0 {
-				if pa := newAssignmentFunc(val); pa != nil {
-					if o := pa.Evaluate(std, ctx); o != nil {
-						value = fmt.Sprintf("%s", o)
-					}
-				}
+	for _, val := range p.Args {
+		if pa := newAssignmentFunc(val); pa != nil {
+			o, err := pa.eval(std, ctx, path)
+			if err != nil {
+				return nil, err
 			}
-		case reflect.Int:
-			// the 3rd arg must be an int
-			if idx == 2 && value != "" && token != "" {
-				output = strings.Split(value, token)[val.(int)]
+			if o != nil {
+				output = fmt.Sprintf("%s%v", output, o)
 			}
+			continue
+		}
+		switch val.(type) {
+		case nil:
+		case string, int, float64, bool:
+			output = fmt.Sprintf("%s%v", output, val)
+		default:
+			return nil, p.errorf(EvalTypeMismatch, "%v can't be concatenated", val)
 		}
 	}
-	if output == "" {
-		return nil
+	return output, nil
+}
+
+func (p *Assignment) evalToken(std *ServiceTemplateDefinition, ctx string, path evalPath) (interface{}, error) {
+	// the first arg is the string to split, or a function resolving to it
+	value := p.Args[0]
+	if pa := newAssignmentFunc(value); pa != nil {
+		o, err := pa.eval(std, ctx, path)
+		if err != nil || o == nil {
+			return nil, err
+		}
+		value = fmt.Sprintf("%v", o)
 	}
-	return output
+	str, ok := value.(string)
+	if !ok {
+		return nil, p.errorf(EvalTypeMismatch, "argument 1 must be a string, got %v", value)
+	}
+	token, ok := p.Args[1].(string)
+	if !ok || token == "" {
+		return nil, p.errorf(EvalTypeMismatch, "argument 2 must be a non empty string, got %v", p.Args[1])
+	}
+	// the 3rd arg must be an int
+	index, ok := p.Args[2].(int)
+	if !ok {
+		return nil, p.errorf(EvalTypeMismatch, "argument 3 must be an integer, got %v", p.Args[2])
+	}
+
+	tokens := strings.Split(str, token)
+	if index < 0 || index >= len(tokens) {
+		return nil, p.errorf(EvalNotFound, "index %d out of range, %q has %d tokens", index, str, len(tokens))
+	}
+	return tokens[index], nil
 }
 
-func (p *Assignment) evalArtifact(std *ServiceTemplateDefinition, ctx string) interface{} {
-	nt, _ := getNTByArgs(std, ctx, p.Args)
-	if nt == nil {
-		return nil
+func (p *Assignment) evalArtifact(std *ServiceTemplateDefinition, ctx string) (interface{}, error) {
+	nt, err := p.nodeTemplateArg(std, ctx)
+	if err != nil {
+		return nil, err
+	}
+	name, err := p.stringArg(1)
+	if err != nil {
+		return nil, err
 	}
 
-	if at, ok := nt.Artifacts[p.Args[1].(string)]; ok {
-		// set default location to the 'temp|tmp' directory to handle 'LOCAL_FILE' being specified
-		// or no location or deploy_path is specified.
-		location := os.TempDir()
-		if loc := get(2, p.Args); loc != "" && loc != LocalFile {
-			location = loc
-		} else if at.DeployPath != "" {
-			location = at.DeployPath
-		}
+	at, ok := nt.Artifacts[name]
+	if !ok {
+		return nil, p.errorf(EvalNotFound, "artifact %q not found in node template %q", name, nt.Name)
+	}
+	// set default location to the 'temp|tmp' directory to handle 'LOCAL_FILE' being specified
+	// or no location or deploy_path is specified.
+	location := os.TempDir()
+	if loc := get(2, p.Args); loc != "" && loc != LocalFile {
+		location = loc
+	} else if at.DeployPath != "" {
+		location = at.DeployPath
+	}
 
-		destFile, err := copyFile(at.File, location)
-		if err != nil {
-			return nil
+	var destFile string
+	if repository := declaredRepository(std.Repositories, at.Repository); repository != "" {
+		// the artifacts of a repository are retrieved with its credential
+		var data []byte
+		if data, err = std.fetchRepositoryFile(repository, at.File); err == nil {
+			destFile, err = writeFile(data, path.Base(at.File), location)
 		}
-		return destFile
+	} else if destFile, err = copyFile(at.File, location); err != nil {
+		err = undeclaredRepositoryError(at.Repository, repository, at.File, err)
 	}
-	return nil
+	if err != nil {
+		return nil, &EvaluationError{Kind: EvalFailed, Function: p.Function, Args: p.Args, Message: fmt.Sprintf("artifact %q could not be copied", name), Err: err}
+	}
+	return destFile, nil
 }
 
-func (p *Assignment) evalProperty(std *ServiceTemplateDefinition, ctx string) interface{} {
-	nt, rnt := getNTByArgs(std, ctx, p.Args)
-	if nt == nil {
-		return nil
+// entityFinder returns the named property or attribute of a Node Template, or
+// of one of its capabilities, and tells if it is declared by their types
+type entityFinder struct {
+	kind     string
+	section  string
+	find     func(nt *NodeTemplate, key, capname string) *Assignment
+	declared func(nt *NodeTemplate, key, capname string) bool
+}
+
+// evalEntity evaluates get_property and get_attribute
+func (p *Assignment) evalEntity(std *ServiceTemplateDefinition, ctx string, path evalPath, f entityFinder) (interface{}, error) {
+	nt, err := p.nodeTemplateArg(std, ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := p.keyArgs(1)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(p.Args) == 2 {
-		if prop := nt.findProperty(p.Args[1].(string), ""); prop != nil {
-			return prop.evaluate(std, nt.Name, "")
-		}
+	type candidate struct {
+		nt      *NodeTemplate
+		key     string
+		capname string
+		keys    []string
 	}
-	if len(p.Args) >= 3 {
-		if rnt != nil {
-			if prop := rnt.findProperty(p.Args[2].(string), p.Args[1].(string)); prop != nil {
-				prop.Args = remainder(3, p.Args)
-				return prop.evaluate(std, rnt.Name, get(3, p.Args))
+	var candidates []candidate
+	if len(keys) >= 2 {
+		// the second arg may be a requirement, or a capability, holding the entity
+		if r := nt.GetRequirement(keys[0]); r != nil {
+			if rnt := std.GetNodeTemplate(r.Node); rnt != nil {
+				candidates = append(candidates, candidate{rnt, keys[1], keys[0], keys[2:]})
 			}
 		}
-		if prop := nt.findProperty(p.Args[2].(string), p.Args[1].(string)); prop != nil {
-			prop.Args = remainder(3, p.Args)
-			return prop.evaluate(std, nt.Name, get(3, p.Args))
+		candidates = append(candidates, candidate{nt, keys[1], keys[0], keys[2:]})
+	}
+	candidates = append(candidates, candidate{nt, keys[0], "", keys[1:]})
+
+	for _, c := range candidates {
+		if a := f.find(c.nt, c.key, c.capname); a != nil {
+			ref := fmt.Sprintf("%s.%s.%s", c.nt.Name, f.section, c.key)
+			if c.capname != "" {
+				ref = fmt.Sprintf("%s.%s.%s.%s", c.nt.Name, c.capname, f.section, c.key)
+			}
+			if path.contains(ref) {
+				chain := append(append([]string{}, path...), ref)
+				return nil, &EvaluationError{Kind: EvalCycle, Function: p.Function, Args: p.Args, Message: "reference cycle: " + strings.Join(chain, " -> "), Chain: chain}
+			}
+			return p.lookup(std, c.nt.Name, append(path, ref), a, c.keys)
 		}
-		if prop := nt.findProperty(p.Args[1].(string), ""); prop != nil {
-			prop.Args = remainder(2, p.Args)
-			return prop.evaluate(std, nt.Name, get(2, p.Args))
+	}
+	for _, c := range candidates {
+		if f.declared(c.nt, c.key, c.capname) {
+			// declared without a value (yet)
+			return nil, nil
 		}
 	}
-	return nil
+	return nil, p.errorf(EvalNotFound, "%s %q not found in node template %q", f.kind, keys[0], nt.Name)
 }
 
-func (p *Assignment) evalAttribute(std *ServiceTemplateDefinition, ctx string) interface{} {
-	nt, rnt := getNTByArgs(std, ctx, p.Args)
-	if nt == nil {
-		return nil
-	}
+func (p *Assignment) evalProperty(std *ServiceTemplateDefinition, ctx string, path evalPath) (interface{}, error) {
+	return p.evalEntity(std, ctx, path, entityFinder{
+		kind:    "property",
+		section: "properties",
+		find: func(nt *NodeTemplate, key, capname string) *Assignment {
+			if prop := nt.findProperty(key, capname); prop != nil {
+				return &prop.Assignment
+			}
+			return nil
+		},
+		declared: func(nt *NodeTemplate, key, capname string) bool {
+			if cd, ok := nt.Refs.Type.Capabilities[capname]; ok {
+				if _, ok := flattenCapType(cd.Type, *std).Properties[key]; ok {
+					return true
+				}
+			}
+			_, ok := nt.Refs.Type.Properties[key]
+			return ok
+		},
+	})
+}
 
-	if len(p.Args) == 2 {
-		if attr := nt.findAttribute(p.Args[1].(string), ""); attr != nil {
-			return attr.evaluate(std, nt.Name, "")
-		}
-	}
-	if len(p.Args) >= 3 {
-		if rnt != nil {
-			if attr := rnt.findAttribute(p.Args[2].(string), p.Args[1].(string)); attr != nil {
-				attr.Args = remainder(3, p.Args)
-				return attr.evaluate(std, rnt.Name, get(3, p.Args))
+func (p *Assignment) evalAttribute(std *ServiceTemplateDefinition, ctx string, path evalPath) (interface{}, error) {
+	return p.evalEntity(std, ctx, path, entityFinder{
+		kind:    "attribute",
+		section: "attributes",
+		find: func(nt *NodeTemplate, key, capname string) *Assignment {
+			if attr := nt.findAttribute(key, capname); attr != nil {
+				return &attr.Assignment
 			}
-		}
-		if attr := nt.findAttribute(p.Args[2].(string), p.Args[1].(string)); attr != nil {
-			attr.Args = remainder(3, p.Args)
-			return attr.evaluate(std, nt.Name, get(3, p.Args))
-		}
-		if attr := nt.findAttribute(p.Args[1].(string), ""); attr != nil {
-			attr.Args = remainder(2, p.Args)
-			return attr.evaluate(std, nt.Name, get(2, p.Args))
+			return nil
+		},
+		declared: func(nt *NodeTemplate, key, capname string) bool {
+			if cd, ok := nt.Refs.Type.Capabilities[capname]; ok {
+				if _, ok := flattenCapType(cd.Type, *std).Attributes[key]; ok {
+					return true
+				}
+			}
+			_, ok := nt.Refs.Type.Attributes[key]
+			return ok
+		},
+	})
+}
+
+// Evaluate gets the value of an Assignment, including the evaluation of expression or function.
+// The value is nil when a function can't be evaluated, EvaluateE tells why.
+func (p *Assignment) Evaluate(std *ServiceTemplateDefinition, ctx string) interface{} {
+	v, _ := p.EvaluateE(std, ctx)
+	return v
+}
+
+// EvaluateE gets the value of an Assignment as Evaluate does, but returns an
+// *EvaluationError when a function can't be evaluated (missing entity, wrong
+// arguments...). A nil value without error is a value not known yet, such as
+// an attribute not set or an operation output not produced.
+func (p *Assignment) EvaluateE(std *ServiceTemplateDefinition, ctx string) (interface{}, error) {
+	return p.eval(std, ctx, nil)
+}
+
+// evalPath is the chain of properties and attributes being evaluated, as
+// <node>[.<capability or requirement>].<properties|attributes>.<name>
+type evalPath []string
+
+func (path evalPath) contains(ref string) bool {
+	for _, r := range path {
+		if r == ref {
+			return true
 		}
 	}
-	return nil
+	return false
 }
 
-// Evaluate gets the value of an Assignment, including the evaluation of expression or function
-func (p *Assignment) Evaluate(std *ServiceTemplateDefinition, ctx string) interface{} {
+func (p *Assignment) eval(std *ServiceTemplateDefinition, ctx string, path evalPath) (interface{}, error) {
 	// TODO(kenjones): Add support for the evaluation of ConstraintClause
 	if p.Value != nil {
-		return p.Value
+		return p.Value, nil
 	}
 
 	switch p.Function {
 	case ConcatFunc:
-		return p.evalConcat(std, ctx)
+		return p.evalConcat(std, ctx, path)
 
 	case TokenFunc:
 		// there are 3 required args
-		if len(p.Args) == 3 {
-			return p.evalToken(std, ctx)
+		if err := p.checkArity(3, 3); err != nil {
+			return nil, err
 		}
+		return p.evalToken(std, ctx, path)
 
 	case GetArtifactFunc:
-		if len(p.Args) > 1 {
-			return p.evalArtifact(std, ctx)
+		if err := p.checkArity(2, 4); err != nil {
+			return nil, err
 		}
+		return p.evalArtifact(std, ctx)
 
 	case GetInputFunc:
-		if len(p.Args) == 1 {
-			return std.GetInputValue(p.Args[0].(string), false)
+		if err := p.checkArity(1, 1); err != nil {
+			return nil, err
+		}
+		name, err := p.stringArg(0)
+		if err != nil {
+			return nil, err
 		}
+		if _, ok := std.TopologyTemplate.Inputs[name]; !ok {
+			return nil, p.errorf(EvalNotFound, "input %q not found", name)
+		}
+		return std.GetInputValue(name, false), nil
 
 	case GetPropFunc:
-		return p.evalProperty(std, ctx)
+		if err := p.checkArity(2, -1); err != nil {
+			return nil, err
+		}
+		return p.evalProperty(std, ctx, path)
 
 	case GetAttrFunc:
-		return p.evalAttribute(std, ctx)
+		if err := p.checkArity(2, -1); err != nil {
+			return nil, err
+		}
+		return p.evalAttribute(std, ctx, path)
+
+	case GetNodesOfTypeFunc:
+		if err := p.checkArity(1, 1); err != nil {
+			return nil, err
+		}
+		name, err := p.stringArg(0)
+		if err != nil {
+			return nil, err
+		}
+		return std.GetNodesOfType(name), nil
+
+	case GetOpOutputFunc:
+		if err := p.checkArity(4, 4); err != nil {
+			return nil, err
+		}
+		return p.evalOperationOutput(std, ctx)
 	}
 
-	return nil
+	return nil, nil
 }
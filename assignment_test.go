@@ -2,6 +2,7 @@ package toscalib
 
 import (
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -624,3 +625,187 @@ func TestEvaluateGetArtifact(t *testing.T) {
 	}
 
 }
+
+func TestEvaluateOperationOutput(t *testing.T) {
+	fname := "./tests/tosca_node_template_attributes.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	aa := s.GetAttribute("frontend", "url")
+	if v := aa.Evaluate(&s, "frontend"); v != nil {
+		t.Log(fname, "evaluation found value for operation output not yet recorded", v)
+		t.Fail()
+	}
+
+	s.SetOperationOutputs("frontend", "tosca.interfaces.node.lifecycle.Standard", "create", map[string]interface{}{
+		"generated_url": "http://frontend:8080",
+	})
+	v := aa.Evaluate(&s, "frontend")
+	if v != "http://frontend:8080" {
+		t.Log(fname, "evaluation failed to get value for `url`", v)
+		t.Fail()
+	}
+
+	if v, ok := s.GetOperationOutput("frontend", "Standard", "configure", "generated_url"); ok {
+		t.Log("found output of an operation not run", v)
+		t.Fail()
+	}
+}
+
+func TestEvaluateNodesOfType(t *testing.T) {
+	fname := "./tests/tosca_get_nodes_of_type.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	expected := map[string][]string{
+		"databases": {"orders_db", "users_db"},
+		"servers":   {"server"},
+		"unknown":   nil,
+	}
+	for name, want := range expected {
+		pa := s.TopologyTemplate.Outputs[name].Value
+		v := pa.Evaluate(&s, "")
+		if !reflect.DeepEqual(v, want) {
+			t.Log(fname, "evaluation of", name, "expected", want, "actual", v)
+			t.Fail()
+		}
+	}
+}
+
+func TestEvaluateE(t *testing.T) {
+	fname := "./tests/tosca_evaluation_errors.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	values := map[string]interface{}{
+		"first_port":      443,
+		"mode":            "production",
+		"first_byte":      "10",
+		"private_address": nil,
+		"description":     nil,
+	}
+	for name, expected := range values {
+		pa := s.TopologyTemplate.Outputs[name].Value
+		v, err := pa.EvaluateE(&s, "")
+		if err != nil || v != expected {
+			t.Errorf("%s: expected %v, got %v (%v)", name, expected, v, err)
+		}
+	}
+
+	kinds := map[string]EvaluationErrorKind{
+		"unknown_node":     EvalNotFound,
+		"unknown_property": EvalNotFound,
+		"unknown_input":    EvalNotFound,
+		"bad_index":        EvalNotFound,
+		"bad_key":          EvalNotFound,
+		"token_range":      EvalNotFound,
+		"scalar_index":     EvalTypeMismatch,
+		"token_index":      EvalTypeMismatch,
+		"input_arity":      EvalBadArity,
+		"property_arity":   EvalBadArity,
+		"missing_file":     EvalFailed,
+	}
+	for name, kind := range kinds {
+		pa := s.TopologyTemplate.Outputs[name].Value
+		v, err := pa.EvaluateE(&s, "")
+		eerr, ok := err.(*EvaluationError)
+		if !ok || eerr.Kind != kind {
+			t.Errorf("%s: expected a %s error, got %v", name, kind, err)
+			continue
+		}
+		if v != nil || pa.Evaluate(&s, "") != nil {
+			t.Errorf("%s: expected no value, got %v", name, v)
+		}
+	}
+}
+
+func TestEvaluateStringKeyedMaps(t *testing.T) {
+	fname := "./tests/get_attribute_with_index.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	// values decoded from JSON, ie. by a state store, have string keys
+	s.SetAttribute("server", "attr_list", []interface{}{"value1", "value2"})
+	s.SetAttribute("server", "nested", map[string]interface{}{
+		"a":    map[string]interface{}{"b": 1},
+		"addr": map[string]interface{}{"get_attribute": []interface{}{"server", "attr_list", 1}},
+	})
+
+	pa := Assignment{Function: GetAttrFunc, Args: []interface{}{"server", "nested", "a", "b"}}
+	if v, err := pa.EvaluateE(&s, ""); err != nil || v != 1 {
+		t.Errorf("expected 1, got %v (%v)", v, err)
+	}
+	pa = Assignment{Function: GetAttrFunc, Args: []interface{}{"server", "nested", "addr"}}
+	if v, err := pa.EvaluateE(&s, ""); err != nil || v != "value2" {
+		t.Errorf("expected value2, got %v (%v)", v, err)
+	}
+}
+
+func TestEvaluateCycles(t *testing.T) {
+	fname := "./tests/tosca_evaluation_cycles.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	chains := map[string][]string{
+		"self_ref": {"self_ref.properties.name", "self_ref.properties.name"},
+		"nested":   {"nested.properties.url", "nested.properties.host", "nested.properties.url"},
+		"cross":    {"front.properties.name", "back.properties.name", "front.attributes.name", "back.properties.name"},
+	}
+	for name, chain := range chains {
+		pa := s.TopologyTemplate.Outputs[name].Value
+		_, err := pa.EvaluateE(&s, "")
+		eerr, ok := err.(*EvaluationError)
+		if !ok || eerr.Kind != EvalCycle {
+			t.Errorf("%s: expected a cycle error, got %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(eerr.Chain, chain) {
+			t.Errorf("%s: expected the chain %v, got %v", name, chain, eerr.Chain)
+		}
+	}
+
+	pa := s.TopologyTemplate.Outputs["front_url"].Value
+	if v, err := pa.EvaluateE(&s, ""); err != nil || v != "http://back.example.com" {
+		t.Errorf("unexpected front_url %v (%v)", v, err)
+	}
+}
@@ -18,12 +18,12 @@ package toscalib
 
 // CapabilityDefinition Appendix 6.1
 type CapabilityDefinition struct {
-	Type             string                         `yaml:"type" json:"type"`                                    //  The required name of the Capability Type the capability definition is based upon.
-	Description      string                         `yaml:"description,omitempty" jsson:"description,omitempty"` // The optional description of the Capability definition.
-	Properties       map[string]PropertyDefinition  `yaml:"properties,omitempty" json:"properties,omitempty"`    //  An optional list of property definitions for the Capability definition.
-	Attributes       map[string]AttributeDefinition `yaml:"attributes" json:"attributes"`                        // An optional list of attribute definitions for the Capability definition.
-	ValidSourceTypes []string                       `yaml:"valid_source_types" json:"valid_source_types"`        // A`n optional list of one or more valid names of Node Types that are supported as valid sources of any relationship established to the declared Capability Type.
-	Occurrences      []string                       `yaml:"occurrences" json:"occurrences"`
+	Type             string                         `yaml:"type" json:"type"`                                       //  The required name of the Capability Type the capability definition is based upon.
+	Description      string                         `yaml:"description,omitempty" jsson:"description,omitempty"`    // The optional description of the Capability definition.
+	Properties       map[string]PropertyDefinition  `yaml:"properties,omitempty" json:"properties,omitempty"`       //  An optional list of property definitions for the Capability definition.
+	Attributes       map[string]AttributeDefinition `yaml:"attributes,omitempty" json:"attributes"`                 // An optional list of attribute definitions for the Capability definition.
+	ValidSourceTypes []string                       `yaml:"valid_source_types,omitempty" json:"valid_source_types"` // A`n optional list of one or more valid names of Node Types that are supported as valid sources of any relationship established to the declared Capability Type.
+	Occurrences      []string                       `yaml:"occurrences,omitempty" json:"occurrences"`
 }
 
 // UnmarshalYAML is used to match both Simple Notation Example and Full Notation Example
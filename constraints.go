@@ -19,6 +19,13 @@ package toscalib
 import (
 	"errors"
 	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/blang/semver"
 )
 
 // Operators is a list of supported constraint operators
@@ -48,11 +55,33 @@ func isOperator(op string) bool {
 // Constraints is an array of ConstraintClause
 type Constraints []ConstraintClause
 
-// IsValid returns true if the value is valid against the Constraints
+// IsValid returns true if the value is valid against the Constraints, otherwise
+// it returns false along with the error of the first ConstraintClause not satisfied.
 func (c *Constraints) IsValid(v interface{}) (bool, error) {
+	for _, constraint := range *c {
+		if err := constraint.Validate(v); err != nil {
+			return false, err
+		}
+	}
 	return true, nil
 }
 
+// ConstraintError is returned when a value does not satisfy a ConstraintClause
+type ConstraintError struct {
+	Operator string
+	Expected interface{}
+	Actual   interface{}
+	Reason   string
+}
+
+func (e *ConstraintError) Error() string {
+	msg := fmt.Sprintf("Constraint %s failed: expected %v, got %v", e.Operator, e.Expected, e.Actual)
+	if e.Reason != "" {
+		msg = fmt.Sprintf("%s (%s)", msg, e.Reason)
+	}
+	return msg
+}
+
 // ConstraintClause definition as described in Appendix 5.2.
 // This is a map where the index is a string that may have a value in
 // {"equal","greater_than", ...} (see Appendix 5.2) a,s value is an interface
@@ -64,7 +93,284 @@ type ConstraintClause struct {
 }
 
 // Evaluate the constraint and return a boolean
-func (constraint *ConstraintClause) Evaluate(interface{}) bool { return true }
+func (constraint *ConstraintClause) Evaluate(v interface{}) bool {
+	return constraint.Validate(v) == nil
+}
+
+// Validate checks the value against the constraint and returns a *ConstraintError
+// describing the operator, the expected value and the actual value when the
+// value does not satisfy the constraint.
+func (constraint *ConstraintClause) Validate(v interface{}) error {
+	fail := func(reason string) error {
+		return &ConstraintError{Operator: constraint.Operator, Expected: constraint.Values, Actual: v, Reason: reason}
+	}
+
+	switch constraint.Operator {
+	case "equal":
+		if !equalValues(v, constraint.Values) {
+			return fail("")
+		}
+
+	case "greater_than", "greater_or_equal", "less_than", "less_or_equal":
+		cmp, err := compareValues(v, constraint.Values)
+		if err != nil {
+			return fail(err.Error())
+		}
+		if !compareResult(constraint.Operator, cmp) {
+			return fail("")
+		}
+
+	case "in_range":
+		bounds := toList(constraint.Values)
+		if len(bounds) != 2 {
+			return fail("in_range requires a lower and an upper bound")
+		}
+		cmp, err := compareValues(v, bounds[0])
+		if err != nil {
+			return fail(err.Error())
+		}
+		if cmp < 0 {
+			return fail("")
+		}
+		if ub, ok := bounds[1].(string); ok && ub == "UNBOUNDED" {
+			return nil
+		}
+		cmp, err = compareValues(v, bounds[1])
+		if err != nil {
+			return fail(err.Error())
+		}
+		if cmp > 0 {
+			return fail("")
+		}
+
+	case "valid_values":
+		for _, vv := range toList(constraint.Values) {
+			if equalValues(v, vv) {
+				return nil
+			}
+		}
+		return fail("")
+
+	case "length", "min_length", "max_length":
+		expected, ok := toFloat(constraint.Values)
+		if !ok {
+			return fail("length must be an integer")
+		}
+		l, err := lengthOf(v)
+		if err != nil {
+			return fail(err.Error())
+		}
+		cmp := 0
+		if float64(l) < expected {
+			cmp = -1
+		} else if float64(l) > expected {
+			cmp = 1
+		}
+		op := map[string]string{"length": "equal", "min_length": "greater_or_equal", "max_length": "less_or_equal"}
+		if !compareResult(op[constraint.Operator], cmp) {
+			return fail(fmt.Sprintf("length is %d", l))
+		}
+
+	case "pattern":
+		p, ok := constraint.Values.(string)
+		if !ok {
+			return fail("pattern must be a string")
+		}
+		re, err := regexp.Compile("^(?:" + p + ")$")
+		if err != nil {
+			return fail(err.Error())
+		}
+		s, ok := v.(string)
+		if !ok {
+			return fail("value is not a string")
+		}
+		if !re.MatchString(s) {
+			return fail("")
+		}
+
+	default:
+		return fmt.Errorf("Unknown Operator: %s", constraint.Operator)
+	}
+	return nil
+}
+
+func compareResult(op string, cmp int) bool {
+	switch op {
+	case "equal":
+		return cmp == 0
+	case "greater_than":
+		return cmp > 0
+	case "greater_or_equal":
+		return cmp >= 0
+	case "less_than":
+		return cmp < 0
+	case "less_or_equal":
+		return cmp <= 0
+	}
+	return false
+}
+
+func equalValues(a, b interface{}) bool {
+	if cmp, err := compareValues(a, b); err == nil {
+		return cmp == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compareValues returns -1, 0 or 1 when a is respectively lower than, equal to
+// or greater than b. Version and Scalar values are compared using their own
+// semantics, with strings converted to the matching type; anything else is
+// compared as a number or a string.
+func compareValues(a, b interface{}) (int, error) {
+	a, b = derefValue(a), derefValue(b)
+
+	_, aIsVer := a.(Version)
+	_, bIsVer := b.(Version)
+	if aIsVer || bIsVer {
+		va, err := toVersion(a)
+		if err != nil {
+			return 0, err
+		}
+		vb, err := toVersion(b)
+		if err != nil {
+			return 0, err
+		}
+		return va.Compare(vb), nil
+	}
+
+	_, aIsScalar := a.(Scalar)
+	_, bIsScalar := b.(Scalar)
+	if !aIsScalar && !bIsScalar {
+		// two strings are compared as scalars if both can be parsed as such
+		if sa, ok := a.(string); ok {
+			if sb, ok := b.(string); ok {
+				if _, err := parseScalar(sa); err == nil {
+					if _, err := parseScalar(sb); err == nil {
+						aIsScalar = true
+					}
+				}
+			}
+		}
+	}
+	if aIsScalar || bIsScalar {
+		sa, err := toScalar(a)
+		if err != nil {
+			return 0, err
+		}
+		sb, err := toScalar(b)
+		if err != nil {
+			return 0, err
+		}
+		if sa.Class() != sb.Class() {
+			return 0, fmt.Errorf("cannot compare %s with %s", sa.Class(), sb.Class())
+		}
+		return compareFloat(sa.BaseValue(), sb.BaseValue()), nil
+	}
+
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			return compareFloat(fa, fb), nil
+		}
+	}
+
+	sa, aok := a.(string)
+	sb, bok := b.(string)
+	if aok && bok {
+		return strings.Compare(sa, sb), nil
+	}
+	return 0, fmt.Errorf("cannot compare %T with %T", a, b)
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func derefValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case *Version:
+		if t != nil {
+			return *t
+		}
+	case *Scalar:
+		if t != nil {
+			return *t
+		}
+	}
+	return v
+}
+
+func toVersion(v interface{}) (semver.Version, error) {
+	switch t := v.(type) {
+	case Version:
+		return t.Version, nil
+	case string:
+		return parseToscaVersion(t)
+	case int, float64:
+		return parseToscaVersion(fmt.Sprintf("%v", t))
+	}
+	return semver.Version{}, fmt.Errorf("%v is not a version", v)
+}
+
+func toScalar(v interface{}) (Scalar, error) {
+	switch t := v.(type) {
+	case Scalar:
+		return t, nil
+	case string:
+		return parseScalar(t)
+	}
+	return Scalar{}, fmt.Errorf("%v is not a scalar-unit", v)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	case reflect.String:
+		if f, err := strconv.ParseFloat(rv.String(), 64); err == nil {
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+func toList(v interface{}) []interface{} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return nil
+	}
+	list := make([]interface{}, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list
+}
+
+func lengthOf(v interface{}) (int, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(rv.String()), nil
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return rv.Len(), nil
+	}
+	return 0, fmt.Errorf("%T has no length", v)
+}
+
+// MarshalYAML converts the constraint to its operator: value notation
+func (constraint ConstraintClause) MarshalYAML() (interface{}, error) {
+	return map[string]interface{}{constraint.Operator: constraint.Values}, nil
+}
 
 // UnmarshalYAML handles simple and complex format when converting from YAML to types
 func (constraint *ConstraintClause) UnmarshalYAML(unmarshal func(interface{}) error) error {
@@ -0,0 +1,101 @@
+package toscalib
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestConstraintClauseEvaluate(t *testing.T) {
+	type check struct {
+		constraint string
+		value      interface{}
+		expected   bool
+	}
+
+	checks := []check{
+		{"equal: 3", 3, true},
+		{"equal: 3", 4, false},
+		{"equal: true", true, true},
+		{"equal: PUBLIC", "PRIVATE", false},
+		{"greater_than: 3", 4, true},
+		{"greater_than: 3", 3, false},
+		{"greater_or_equal: 3", 3, true},
+		{"greater_or_equal: 0.1 GHz", "2 GHz", true},
+		{"greater_or_equal: 0.1 GHz", "50 MHz", false},
+		{"greater_or_equal: 0.1 GHz", "1 GB", false},
+		{"greater_or_equal: 0 MB", Scalar{Value: 4, Unit: "GB"}, true},
+		{"less_than: 1 GiB", "1000 MiB", true},
+		{"less_than: 1 GiB", "1 GB", true},
+		{"less_or_equal: 2 h", "120 m", true},
+		{"less_or_equal: 2 h", "121 m", false},
+		{"in_range: [ 1, 65535 ]", 8080, true},
+		{"in_range: [ 1, 65535 ]", 0, false},
+		{"in_range: [ 1, 65535 ]", 65536, false},
+		{"in_range: [ 1, UNBOUNDED ]", 99999999, true},
+		{"valid_values: [ 1, 2, 4, 8 ]", 4, true},
+		{"valid_values: [ 1, 2, 4, 8 ]", 3, false},
+		{"valid_values: [ source, target, peer ]", "peer", true},
+		{"length: 4", "abcd", true},
+		{"length: 4", "abc", false},
+		{"min_length: 1", []interface{}{"a"}, true},
+		{"min_length: 1", []interface{}{}, false},
+		{"max_length: 2", map[string]interface{}{"a": 1, "b": 2, "c": 3}, false},
+		{"pattern: '[a-z]+[0-9]*'", "server01", true},
+		{"pattern: '[a-z]+[0-9]*'", "Server01", false},
+	}
+
+	for _, c := range checks {
+		var cc ConstraintClause
+		if err := yaml.Unmarshal([]byte(c.constraint), &cc); err != nil {
+			t.Fatal(c.constraint, err)
+		}
+		if got := cc.Evaluate(c.value); got != c.expected {
+			t.Errorf("%s with %v: expected %v, actual %v", c.constraint, c.value, c.expected, got)
+		}
+	}
+}
+
+func TestConstraintClauseVersion(t *testing.T) {
+	var v Version
+	if err := yaml.Unmarshal([]byte("5.7.1"), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	cc := ConstraintClause{Operator: "greater_or_equal", Values: "5.5"}
+	if !cc.Evaluate(v) {
+		t.Errorf("version %v should satisfy %v", v, cc)
+	}
+
+	cc = ConstraintClause{Operator: "less_than", Values: "5.7"}
+	if cc.Evaluate(&v) {
+		t.Errorf("version %v should not satisfy %v", v, cc)
+	}
+}
+
+func TestConstraintsIsValid(t *testing.T) {
+	var c Constraints
+	data := `
+- greater_or_equal: 1
+- less_than: 10
+`
+	if err := yaml.Unmarshal([]byte(data), &c); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := c.IsValid(5); !ok || err != nil {
+		t.Errorf("expected 5 to be valid, actual %v %v", ok, err)
+	}
+
+	ok, err := c.IsValid(10)
+	if ok || err == nil {
+		t.Fatal("expected 10 to be invalid")
+	}
+	cerr, isCE := err.(*ConstraintError)
+	if !isCE {
+		t.Fatalf("expected a *ConstraintError, actual %T", err)
+	}
+	if cerr.Operator != "less_than" || cerr.Actual != 10 {
+		t.Errorf("unexpected error content: %v", cerr)
+	}
+}
@@ -0,0 +1,418 @@
+package toscalib
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// OperationCall describes an operation to be run by a Dispatcher
+type OperationCall struct {
+	Step           string                 // The name of the workflow step calling the operation.
+	Node           string                 // The name of the Node Template the operation is run on.
+	Instance       string                 // The ID of the node instance the operation is run on, when the Executor tracks instances.
+	Relationship   string                 // The name of the requirement of Node, when the operation belongs to its relationship.
+	Interface      string                 // The name of the interface (ie. Standard).
+	Operation      string                 // The name of the operation (ie. create).
+	Implementation string                 // The implementation artifact of the operation.
+	Inputs         map[string]interface{} // The evaluated inputs of the interface and of the operation.
+	Definition     OperationDefinition    // The definition of the operation.
+	Delegate       string                 // The name of the workflow delegated to the orchestrator, set instead of the operation.
+}
+
+// Dispatcher runs the operations called by the workflows and returns their outputs.
+// Dispatch may be called concurrently for independent steps.
+type Dispatcher interface {
+	Dispatch(ctx context.Context, call OperationCall) (map[string]interface{}, error)
+}
+
+// DispatcherFunc is an adapter to use an ordinary function as a Dispatcher
+type DispatcherFunc func(ctx context.Context, call OperationCall) (map[string]interface{}, error)
+
+// Dispatch calls f(ctx, call)
+func (f DispatcherFunc) Dispatch(ctx context.Context, call OperationCall) (map[string]interface{}, error) {
+	return f(ctx, call)
+}
+
+// LifecycleDispatcher dispatches the operations of the Standard interface of the
+// Node Templates to their ToscaInterfacesNodeLifecycleStandarder, indexed by Node
+// Template name. The other operations are ignored.
+type LifecycleDispatcher map[string]ToscaInterfacesNodeLifecycleStandarder
+
+// Dispatch runs the lifecycle operation matching the call
+func (d LifecycleDispatcher) Dispatch(ctx context.Context, call OperationCall) (map[string]interface{}, error) {
+	if call.Relationship != "" || call.Delegate != "" || !sameInterface(call.Interface, StandardInterface) {
+		return nil, nil
+	}
+	lc, ok := d[call.Node]
+	if !ok {
+		return nil, fmt.Errorf("No lifecycle registered for node template %q", call.Node)
+	}
+	switch call.Operation {
+	case "create":
+		return nil, lc.Create()
+	case "configure":
+		return nil, lc.Configure()
+	case "start":
+		return nil, lc.Start()
+	case "stop":
+		return nil, lc.Stop()
+	case "delete":
+		return nil, lc.Delete()
+	}
+	return nil, fmt.Errorf("Unknown lifecycle operation %q", call.Operation)
+}
+
+// OperationResult is the outcome of an operation called by a step
+type OperationResult struct {
+	Call    OperationCall
+	Outputs map[string]interface{}
+	Err     error
+}
+
+// StepResult is the outcome of a workflow step
+type StepResult struct {
+	Status     StepStatus
+	Operations []OperationResult // The operations called by the step, in order.
+	Err        error             // The error that made the step fail.
+}
+
+// ExecutionResult is the outcome of the execution of a workflow
+type ExecutionResult struct {
+	Workflow string
+	Steps    map[string]*StepResult // The result of every step of the workflow, including the ones not run.
+}
+
+// WorkflowError is returned when steps of a workflow failed
+type WorkflowError struct {
+	Workflow string
+	Steps    map[string]error // The errors of the failed steps.
+}
+
+func (e *WorkflowError) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Workflow %q failed:", e.Workflow)
+	for _, name := range sortedKeys(e.Steps) {
+		fmt.Fprintf(&buf, "\n  step %s: %v", name, e.Steps[name])
+	}
+	return buf.String()
+}
+
+// Executor runs workflows by walking their plan and calling the Dispatcher for every
+// operation of their steps. The steps that do not depend on each other run
+// concurrently, at most Parallelism at a time (one when Parallelism is not set),
+// the steps of the inlined workflows included.
+// The outputs of the operations are recorded for get_operation_output.
+// When Instances is set the activities run on every instance of the targeted Node
+// Templates and the set_state activities move the instances through the normative
+// lifecycle, otherwise they are recorded as the state attribute of the Node Templates.
+type Executor struct {
+	Dispatcher  Dispatcher
+	Parallelism int
+	Instances   *InstanceModel
+
+	mu sync.Mutex // guards the Service Template while steps run
+}
+
+// Run compiles the named workflow of the Service Template (see CompileWorkflow)
+// and executes it.
+func (e *Executor) Run(ctx context.Context, s *ServiceTemplateDefinition, workflow string) (*ExecutionResult, error) {
+	e.mu.Lock()
+	p, err := s.CompileWorkflow(workflow)
+	e.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return e.RunPlan(ctx, s, p)
+}
+
+// RunPlan executes the plan until all its steps completed or were skipped. Once the
+// context is done no step is started anymore, the running ones are waited for and
+// the error of the context is returned. A *WorkflowError is returned when steps failed.
+func (e *Executor) RunPlan(ctx context.Context, s *ServiceTemplateDefinition, p *WorkflowPlan) (*ExecutionResult, error) {
+	res := &ExecutionResult{Workflow: p.Name, Steps: make(map[string]*StepResult, len(p.Steps))}
+
+	// the inlined workflows share the slots of the workflow inlining them
+	slots, ok := ctx.Value(slotsKey{}).(executionSlots)
+	if !ok {
+		parallelism := e.Parallelism
+		if parallelism < 1 {
+			parallelism = 1
+		}
+		slots = make(executionSlots, parallelism)
+		ctx = context.WithValue(ctx, slotsKey{}, slots)
+	}
+
+	type done struct {
+		step string
+		res  *StepResult
+	}
+	results := make(chan done, len(p.Steps))
+	running := 0
+	for {
+		if ctx.Err() == nil {
+			for _, step := range p.Runnable() {
+				if !slots.acquire(ctx, running == 0) {
+					break
+				}
+				if err := p.Start(step); err != nil {
+					slots.release()
+					return res, err
+				}
+				running++
+				go func(step string) {
+					sr := e.runStep(ctx, s, step, p.Steps[step])
+					slots.release()
+					results <- done{step, sr}
+				}(step)
+			}
+		}
+		if running == 0 {
+			break
+		}
+
+		d := <-results
+		running--
+		res.Steps[d.step] = d.res
+		if d.res.Err != nil {
+			p.Fail(d.step)
+		} else {
+			p.Succeed(d.step)
+		}
+	}
+
+	werr := &WorkflowError{Workflow: p.Name, Steps: make(map[string]error)}
+	for _, step := range p.Order {
+		sr, ok := res.Steps[step]
+		if !ok {
+			sr = &StepResult{}
+			res.Steps[step] = sr
+		}
+		sr.Status = p.Status(step)
+		if sr.Status == StepFailed {
+			werr.Steps[step] = sr.Err
+		}
+	}
+
+	if err := ctx.Err(); err != nil && !p.Done() {
+		return res, err
+	}
+	if len(werr.Steps) != 0 {
+		return res, werr
+	}
+	return res, nil
+}
+
+// runStep runs the activities of a step on each of its target Node Templates
+func (e *Executor) runStep(ctx context.Context, s *ServiceTemplateDefinition, name string, step StepDefinition) *StepResult {
+	res := &StepResult{}
+
+	e.mu.Lock()
+	nodes := []string{step.Target}
+	if gd, ok := s.TopologyTemplate.Groups[step.Target]; ok {
+		nodes = gd.Members
+	}
+	e.mu.Unlock()
+
+	for _, node := range nodes {
+		instances := []string{""}
+		if e.Instances != nil {
+			instances = nil
+			for _, inst := range e.Instances.Instances(node) {
+				instances = append(instances, inst.ID)
+			}
+		}
+		for _, instance := range instances {
+			if res.Err = e.runActivities(ctx, s, res, name, step, node, instance); res.Err != nil {
+				return res
+			}
+		}
+	}
+	return res
+}
+
+// runActivities runs the activities of a step on a Node Template, or one of its instances
+func (e *Executor) runActivities(ctx context.Context, s *ServiceTemplateDefinition, res *StepResult, name string, step StepDefinition, node, instance string) error {
+	for _, act := range step.Activities {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		switch {
+		case act.SetState != "":
+			if instance == "" {
+				e.mu.Lock()
+				s.SetAttribute(node, "state", act.SetState)
+				e.mu.Unlock()
+				continue
+			}
+			state, err := ParseState(act.SetState)
+			if err != nil {
+				return err
+			}
+			if err := e.Instances.SetState(instance, state); err != nil {
+				return err
+			}
+
+		case act.CallOperation != "":
+			call, ok, err := e.operationCall(s, name, node, step.TargetRelationship, act.CallOperation)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				// operations without implementation have nothing to run
+				continue
+			}
+			call.Instance = instance
+			if err := e.dispatch(ctx, s, res, call); err != nil {
+				return err
+			}
+
+		case act.Delegate != "":
+			call := OperationCall{Step: name, Node: node, Instance: instance, Delegate: act.Delegate}
+			if err := e.dispatch(ctx, s, res, call); err != nil {
+				return err
+			}
+
+		case act.Inline != "":
+			// the step gives its slot to the steps of the inlined workflow
+			slots, _ := ctx.Value(slotsKey{}).(executionSlots)
+			slots.release()
+			_, err := e.Run(ctx, s, act.Inline)
+			slots <- struct{}{}
+			if err != nil {
+				return fmt.Errorf("Inlined workflow %q failed: %v", act.Inline, err)
+			}
+		}
+	}
+	return nil
+}
+
+type slotsKey struct{}
+
+// executionSlots limits the number of steps running at once
+type executionSlots chan struct{}
+
+// acquire takes a slot, waiting for one to be released when wait is set and until
+// the context is done, it reports whether a slot was taken.
+func (sl executionSlots) acquire(ctx context.Context, wait bool) bool {
+	if !wait {
+		select {
+		case sl <- struct{}{}:
+			return true
+		default:
+			return false
+		}
+	}
+	select {
+	case sl <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (sl executionSlots) release() {
+	<-sl
+}
+
+func (e *Executor) dispatch(ctx context.Context, s *ServiceTemplateDefinition, res *StepResult, call OperationCall) error {
+	outputs, err := e.Dispatcher.Dispatch(ctx, call)
+	res.Operations = append(res.Operations, OperationResult{Call: call, Outputs: outputs, Err: err})
+	if err != nil {
+		if call.Delegate != "" {
+			return fmt.Errorf("Delegated workflow %q failed on %q: %v", call.Delegate, call.Node, err)
+		}
+		return fmt.Errorf("Operation %s.%s failed on %q: %v", call.Interface, call.Operation, call.Node, err)
+	}
+
+	if call.Delegate == "" && len(outputs) != 0 {
+		entity := call.Node
+		if call.Relationship != "" {
+			entity = s.requirementRelationshipName(call.Node, call.Relationship)
+		}
+		e.mu.Lock()
+		s.SetOperationOutputs(entity, call.Interface, call.Operation, outputs)
+		e.mu.Unlock()
+	}
+	return nil
+}
+
+// operationCall resolves the operation called by an activity and evaluates its
+// inputs, it returns false when the operation has no implementation.
+func (e *Executor) operationCall(s *ServiceTemplateDefinition, step, node, requirement, op string) (OperationCall, bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	call := OperationCall{Step: step, Node: node, Relationship: requirement}
+	idx := strings.LastIndex(op, ".")
+	if idx <= 0 {
+		return call, false, fmt.Errorf("Invalid operation %q", op)
+	}
+	call.Interface, call.Operation = op[:idx], op[idx+1:]
+
+	nt := s.GetNodeTemplate(node)
+	if nt == nil {
+		return call, false, fmt.Errorf("Node template %q not found", node)
+	}
+	intfs, ctx := nt.Interfaces, node
+	if requirement != "" {
+		intfs = s.relationshipInterfaces(node, requirement, s.requirementRelationship(nt, requirement))
+		ctx = s.requirementRelationshipName(node, requirement)
+	}
+
+	def, ok := findOperation(intfs, call.Interface, call.Operation)
+	if !ok {
+		return call, false, fmt.Errorf("Operation %q is not declared by %q", op, node)
+	}
+	if def.Implementation == "" {
+		return call, false, nil
+	}
+	call.Definition = def
+	call.Implementation = def.Implementation
+
+	// the inputs of the operation override the ones of its interface
+	inputs := make(map[string]PropertyAssignment)
+	for _, name := range sortedKeys(intfs) {
+		if sameInterface(name, call.Interface) || sameInterface(intfs[name].Type, call.Interface) {
+			for k, pa := range intfs[name].Inputs {
+				inputs[k] = pa
+			}
+			break
+		}
+	}
+	for k, pa := range def.Inputs {
+		inputs[k] = pa
+	}
+	call.Inputs = make(map[string]interface{})
+	for _, k := range sortedKeys(inputs) {
+		pa := inputs[k]
+		v, err := pa.EvaluateE(s, ctx)
+		if err != nil {
+			return call, false, fmt.Errorf("Input %q of operation %q of %q: %v", k, op, node, err)
+		}
+		call.Inputs[k] = v
+	}
+	return call, true, nil
+}
+
+// requirementRelationshipName returns the name the relationship of a requirement
+// is known by: its Relationship Template, or <node>.<requirement> when it only
+// gives a Relationship Type, as several requirements may use the same type.
+func (s *ServiceTemplateDefinition) requirementRelationshipName(node, requirement string) string {
+	if nt := s.GetNodeTemplate(node); nt != nil {
+		if req := nt.GetRequirement(requirement); req != nil {
+			if _, ok := s.TopologyTemplate.RelationshipTemplates[req.Relationship.Type]; ok {
+				return req.Relationship.Type
+			}
+			return requirementKey(node, requirement)
+		}
+	}
+	return ""
+}
+
+func requirementKey(node, requirement string) string {
+	return node + "." + requirement
+}
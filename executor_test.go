@@ -0,0 +1,267 @@
+package toscalib
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDispatcher records the operations it is called for
+type fakeDispatcher struct {
+	mu      sync.Mutex
+	calls   []OperationCall
+	fail    map[string]bool // operations failing, as node.operation
+	running int
+	max     int
+}
+
+func (d *fakeDispatcher) Dispatch(ctx context.Context, call OperationCall) (map[string]interface{}, error) {
+	d.mu.Lock()
+	d.calls = append(d.calls, call)
+	d.running++
+	if d.running > d.max {
+		d.max = d.running
+	}
+	d.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	d.mu.Lock()
+	d.running--
+	d.mu.Unlock()
+	if d.fail[call.Node+"."+call.Operation] {
+		return nil, errors.New("boom")
+	}
+	return map[string]interface{}{"implementation": call.Implementation}, nil
+}
+
+func (d *fakeDispatcher) index(node, op string) int {
+	for i, c := range d.calls {
+		if c.Node == node && c.Operation == op {
+			return i
+		}
+	}
+	return -1
+}
+
+func parseWordpress(t *testing.T) *ServiceTemplateDefinition {
+	fname := "./tests/tosca_single_instance_wordpress.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+	return &s
+}
+
+func TestExecutorDeploy(t *testing.T) {
+	s := parseWordpress(t)
+	d := &fakeDispatcher{}
+	e := &Executor{Dispatcher: d, Parallelism: 2}
+
+	res, err := e.Run(context.Background(), s, DeployWorkflow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.calls) != 8 {
+		t.Errorf("expected 8 operation calls, got %d: %v", len(d.calls), d.calls)
+	}
+	if d.max > 2 {
+		t.Errorf("expected at most 2 concurrent operations, got %d", d.max)
+	}
+	// node and operation running before another node and operation
+	order := [][4]string{
+		{"mysql_dbms", "create", "mysql_database", "configure"},
+		{"webserver", "start", "wordpress", "create"},
+		{"mysql_database", "configure", "wordpress", "configure"},
+		{"wordpress", "create", "wordpress", "configure"},
+	}
+	for _, o := range order {
+		before, after := d.index(o[0], o[1]), d.index(o[2], o[3])
+		if before == -1 || after == -1 || before > after {
+			t.Errorf("expected %s.%s to run before %s.%s", o[0], o[1], o[2], o[3])
+		}
+	}
+
+	for name, sr := range res.Steps {
+		if sr.Status != StepSucceeded {
+			t.Errorf("expected step %s to succeed, got %s", name, sr.Status)
+		}
+	}
+	call := res.Steps["wordpress_configure"].Operations[0].Call
+	if call.Implementation != "wordpress/wordpress_configure.sh" || call.Inputs["wp_db_name"] != "wordpress" {
+		t.Errorf("unexpected call %v", call)
+	}
+
+	if v, ok := s.GetOperationOutput("mysql_dbms", "Standard", "start", "implementation"); !ok || v != "mysql/mysql_dbms_start.sh" {
+		t.Errorf("operation output not recorded, got %v", v)
+	}
+	if attr := s.GetAttribute("wordpress", "state"); attr == nil || attr.Evaluate(s, "wordpress") != "started" {
+		t.Errorf("expected wordpress to be started, got %v", attr)
+	}
+}
+
+func TestExecutorFailure(t *testing.T) {
+	s := parseWordpress(t)
+	d := &fakeDispatcher{fail: map[string]bool{"webserver.start": true}}
+	e := &Executor{Dispatcher: d, Parallelism: 4}
+
+	res, err := e.Run(context.Background(), s, DeployWorkflow)
+	werr, ok := err.(*WorkflowError)
+	if !ok {
+		t.Fatalf("expected a *WorkflowError, got %v", err)
+	}
+	if _, ok := werr.Steps["webserver_start"]; !ok || len(werr.Steps) != 1 {
+		t.Errorf("unexpected failed steps %v", werr)
+	}
+	if res.Steps["wordpress_create"].Status != StepSkipped {
+		t.Errorf("expected wordpress_create to be skipped, got %s", res.Steps["wordpress_create"].Status)
+	}
+	if res.Steps["mysql_database_start"].Status != StepSucceeded {
+		t.Errorf("expected the independent branch to complete, got %s", res.Steps["mysql_database_start"].Status)
+	}
+	if d.index("wordpress", "create") != -1 {
+		t.Error("wordpress should not have been created")
+	}
+}
+
+func TestExecutorInputErrors(t *testing.T) {
+	s := parseWordpress(t)
+	nt := s.TopologyTemplate.NodeTemplates["wordpress"]
+	op := nt.Interfaces["Standard"].Operations["configure"]
+	op.Inputs["wp_db_name"] = PropertyAssignment{Assignment{Function: GetPropFunc, Args: []interface{}{"unknown", "name"}}}
+	d := &fakeDispatcher{}
+	e := &Executor{Dispatcher: d}
+
+	_, err := e.Run(context.Background(), s, DeployWorkflow)
+	werr, ok := err.(*WorkflowError)
+	if !ok {
+		t.Fatalf("expected a *WorkflowError, got %v", err)
+	}
+	if serr, ok := werr.Steps["wordpress_configure"]; !ok || !strings.Contains(serr.Error(), `"wp_db_name"`) {
+		t.Errorf("unexpected failed steps %v", werr)
+	}
+	if d.index("wordpress", "configure") != -1 {
+		t.Error("the operation must not be called with an input that can't be evaluated")
+	}
+}
+
+func TestExecutorCancel(t *testing.T) {
+	s := parseWordpress(t)
+	d := &fakeDispatcher{}
+	e := &Executor{Dispatcher: d}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := e.Run(ctx, s, DeployWorkflow); err != context.Canceled {
+		t.Errorf("expected the execution to be canceled, got %v", err)
+	}
+	if len(d.calls) != 0 {
+		t.Errorf("unexpected calls %v", d.calls)
+	}
+}
+
+type fakeLifecycle struct {
+	ops []string
+}
+
+func (l *fakeLifecycle) Create() error    { l.ops = append(l.ops, "create"); return nil }
+func (l *fakeLifecycle) Configure() error { l.ops = append(l.ops, "configure"); return nil }
+func (l *fakeLifecycle) Start() error     { l.ops = append(l.ops, "start"); return nil }
+func (l *fakeLifecycle) Stop() error      { l.ops = append(l.ops, "stop"); return nil }
+func (l *fakeLifecycle) Delete() error    { l.ops = append(l.ops, "delete"); return nil }
+
+func TestLifecycleDispatcher(t *testing.T) {
+	s := parseWordpress(t)
+	lcs := make(LifecycleDispatcher)
+	wordpress := &fakeLifecycle{}
+	for _, name := range sortedKeys(s.TopologyTemplate.NodeTemplates) {
+		lcs[name] = &fakeLifecycle{}
+	}
+	lcs["wordpress"] = wordpress
+
+	e := &Executor{Dispatcher: lcs}
+	if _, err := e.Run(context.Background(), s, DeployWorkflow); err != nil {
+		t.Fatal(err)
+	}
+	if len(wordpress.ops) != 2 || wordpress.ops[0] != "create" || wordpress.ops[1] != "configure" {
+		t.Errorf("unexpected lifecycle operations %v", wordpress.ops)
+	}
+}
+
+func TestExecutorRelationshipOutputs(t *testing.T) {
+	fname := "./tests/tosca_relationship_operations.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	d := &fakeDispatcher{}
+	e := &Executor{Dispatcher: d, Parallelism: 2}
+	res, err := e.Run(context.Background(), &s, DeployWorkflow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the relationships share their type, they are known by their requirement
+	for req, db := range map[string]string{"primary": "primary_db", "replica": "replica_db"} {
+		step := res.Steps["app_"+req+"_pre_configure_source"]
+		if step == nil || len(step.Operations) != 1 {
+			t.Fatalf("unexpected step %s: %v", req, step)
+		}
+		if name := step.Operations[0].Call.Inputs["db_name"]; name != strings.TrimSuffix(db, "_db") {
+			t.Errorf("%s: expected the target %s, got %v", req, db, name)
+		}
+		v, ok := s.GetOperationOutput("app."+req, "Configure", "pre_configure_source", "implementation")
+		if !ok || v != "app/connect_"+req+".sh" {
+			t.Errorf("%s: unexpected operation output %v", req, v)
+		}
+	}
+}
+
+func TestExecutorInlineParallelism(t *testing.T) {
+	fname := "./tests/tosca_inline_workflows.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	d := &fakeDispatcher{}
+	e := &Executor{Dispatcher: d, Parallelism: 2}
+	if _, err := e.Run(context.Background(), &s, "install"); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.calls) != 4 {
+		t.Errorf("expected 4 operation calls, got %d: %v", len(d.calls), d.calls)
+	}
+	// the inlined workflow runs within the parallelism of the executor
+	if d.max > 2 {
+		t.Errorf("expected at most 2 concurrent operations, got %d", d.max)
+	}
+
+	e = &Executor{Dispatcher: &fakeDispatcher{}}
+	if _, err := e.Run(context.Background(), &s, "install"); err != nil {
+		t.Errorf("the inlined workflow must run with a single slot, got %v", err)
+	}
+}
@@ -10,6 +10,7 @@ type flatTypes struct {
 	Nodes         map[string]NodeType
 	Groups        map[string]GroupType
 	Policies      map[string]PolicyType
+	DataTypes     map[string]DataType
 }
 
 func flattenArtType(name string, s ServiceTemplateDefinition) ArtifactType {
@@ -195,6 +196,33 @@ func flattenPolicyType(name string, s ServiceTemplateDefinition) PolicyType {
 	return PolicyType{}
 }
 
+func flattenDataType(name string, s ServiceTemplateDefinition) DataType {
+	if dt, ok := s.DataTypes[name]; ok {
+		if dt.DerivedFrom != "" {
+			parent := flattenDataType(dt.DerivedFrom, s)
+
+			// clone the parent first before applying any changes
+			tmp := clone(parent)
+			dtm, _ := tmp.(DataType)
+
+			// mergo does not handle merging Slices so the items
+			// will wipe away, capture the values here.
+			constraints := dtm.Constraints
+
+			_ = mergo.MergeWithOverwrite(&dtm, dt)
+
+			// now copy them back in using append, if the child type had
+			// any previously, otherwise it will duplicate the parents.
+			if len(dt.Constraints) > 0 {
+				dtm.Constraints = append(dtm.Constraints, constraints...)
+			}
+			return dtm
+		}
+		return dt
+	}
+	return DataType{}
+}
+
 func flattenHierarchy(s ServiceTemplateDefinition) flatTypes {
 	var flats flatTypes
 
@@ -263,5 +291,10 @@ func flattenHierarchy(s ServiceTemplateDefinition) flatTypes {
 		flats.Policies[name] = flattenPolicyType(name, s)
 	}
 
+	flats.DataTypes = make(map[string]DataType)
+	for name := range s.DataTypes {
+		flats.DataTypes[name] = flattenDataType(name, s)
+	}
+
 	return flats
 }
@@ -0,0 +1,185 @@
+package toscalib
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DependencyEdge is a relationship from the Node Template holding a requirement
+// (Source) to the Node Template fulfilling it (Target).
+type DependencyEdge struct {
+	Source       string // The name of the Node Template depending on Target.
+	Target       string // The name of the Node Template fulfilling the requirement.
+	Requirement  string // The name of the requirement of Source.
+	Relationship string // The Relationship Type of the edge.
+}
+
+// DependencyGraph is the graph of the Node Templates of a topology linked by the
+// relationships of their requirements.
+type DependencyGraph struct {
+	Nodes []string         // The names of the Node Templates, in alphabetical order.
+	Edges []DependencyEdge // The relationships between the Node Templates.
+}
+
+// CycleError is returned when the Node Templates of a topology depend on each other.
+type CycleError struct {
+	Cycle []string // The Node Templates forming the cycle, the first one is repeated at the end.
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("Dependency cycle detected: %s", strings.Join(e.Cycle, " -> "))
+}
+
+// DependencyGraph builds the graph of the topology from its requirements, the
+// abstract requirements being bound as with MatchRequirements. The requirements
+// that can't be fulfilled are not part of the graph.
+func (s *ServiceTemplateDefinition) DependencyGraph() *DependencyGraph {
+	g := &DependencyGraph{Nodes: sortedKeys(s.TopologyTemplate.NodeTemplates)}
+
+	bindings, _ := s.MatchRequirements()
+	for _, b := range bindings {
+		rel := b.Relationship
+		if rt, ok := s.TopologyTemplate.RelationshipTemplates[rel]; ok {
+			rel = rt.Type
+		}
+		if rel == "" {
+			rel = "tosca.relationships.DependsOn"
+		}
+		g.Edges = append(g.Edges, DependencyEdge{
+			Source:       b.Source,
+			Target:       b.Target,
+			Requirement:  b.Requirement,
+			Relationship: rel,
+		})
+	}
+	return g
+}
+
+// DependsOn returns the names of the Node Templates the named one depends on
+func (g *DependencyGraph) DependsOn(name string) []string {
+	var names []string
+	for _, e := range g.Edges {
+		if e.Source == name && !contains(names, e.Target) {
+			names = append(names, e.Target)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Dependents returns the names of the Node Templates depending on the named one
+func (g *DependencyGraph) Dependents(name string) []string {
+	var names []string
+	for _, e := range g.Edges {
+		if e.Target == name && !contains(names, e.Source) {
+			names = append(names, e.Source)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FindCycle returns the first dependency cycle found in the graph, or nil
+func (g *DependencyGraph) FindCycle() []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var stack []string
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, dep := range g.DependsOn(name) {
+			switch state[dep] {
+			case visiting:
+				for i, n := range stack {
+					if n == dep {
+						cycle := append([]string{}, stack[i:]...)
+						return append(cycle, dep)
+					}
+				}
+			case unvisited:
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = visited
+		return nil
+	}
+
+	for _, name := range g.Nodes {
+		if state[name] == unvisited {
+			if cycle := visit(name); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// Tiers returns the Node Templates grouped by tiers: the first tier holds the Node
+// Templates without dependencies, each following tier the Node Templates depending
+// only on the previous tiers. The Node Templates of a tier can be deployed in parallel.
+// A *CycleError is returned when the graph has a cycle.
+func (g *DependencyGraph) Tiers() ([][]string, error) {
+	if cycle := g.FindCycle(); cycle != nil {
+		return nil, &CycleError{Cycle: cycle}
+	}
+
+	remaining := make(map[string]int, len(g.Nodes))
+	for _, name := range g.Nodes {
+		remaining[name] = len(g.DependsOn(name))
+	}
+
+	var tiers [][]string
+	for len(remaining) > 0 {
+		var tier []string
+		for _, name := range sortedKeys(remaining) {
+			if remaining[name] == 0 {
+				tier = append(tier, name)
+			}
+		}
+		for _, name := range tier {
+			delete(remaining, name)
+			for _, dependent := range g.Dependents(name) {
+				remaining[dependent]--
+			}
+		}
+		tiers = append(tiers, tier)
+	}
+	return tiers, nil
+}
+
+// DeployOrder returns the Node Templates in the order they have to be deployed,
+// every Node Template coming after the ones it depends on.
+func (g *DependencyGraph) DeployOrder() ([]string, error) {
+	tiers, err := g.Tiers()
+	if err != nil {
+		return nil, err
+	}
+	var order []string
+	for _, tier := range tiers {
+		order = append(order, tier...)
+	}
+	return order, nil
+}
+
+// UndeployOrder returns the Node Templates in the order they have to be undeployed,
+// which is the reverse of the deploy order.
+func (g *DependencyGraph) UndeployOrder() ([]string, error) {
+	order, err := g.DeployOrder()
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+	return order, nil
+}
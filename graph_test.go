@@ -0,0 +1,79 @@
+package toscalib
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDependencyGraph(t *testing.T) {
+	fname := "./tests/tosca_requirement_matching.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	g := s.DependencyGraph()
+	if len(g.Nodes) != 7 || len(g.Edges) != 4 {
+		t.Fatalf("unexpected graph %v", g)
+	}
+	for _, e := range g.Edges {
+		if e.Source == "my_app" && e.Relationship != "tosca.relationships.ConnectsTo" {
+			t.Errorf("expected edge %v to be a ConnectsTo relationship", e)
+		}
+	}
+	if deps := g.Dependents("mysql"); !reflect.DeepEqual(deps, []string{"app_db", "legacy_db"}) {
+		t.Errorf("unexpected dependents of mysql %v", deps)
+	}
+
+	tiers, err := g.Tiers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"arm_server", "db_server", "small_server"},
+		{"mysql"},
+		{"app_db", "legacy_db"},
+		{"my_app"},
+	}
+	if !reflect.DeepEqual(tiers, expected) {
+		t.Errorf("expected tiers %v, actual %v", expected, tiers)
+	}
+
+	order, err := g.UndeployOrder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order[0] != "my_app" || order[len(order)-1] != "arm_server" {
+		t.Errorf("unexpected undeploy order %v", order)
+	}
+}
+
+func TestDependencyGraphCycle(t *testing.T) {
+	fname := "./tests/invalids/test_dependency_cycle.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	g := s.DependencyGraph()
+	if cycle := g.FindCycle(); !reflect.DeepEqual(cycle, []string{"first", "second", "third", "first"}) {
+		t.Errorf("unexpected cycle %v", cycle)
+	}
+	_, err = g.DeployOrder()
+	if _, ok := err.(*CycleError); !ok {
+		t.Fatalf("expected a *CycleError, actual %v", err)
+	}
+}
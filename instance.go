@@ -0,0 +1,262 @@
+package toscalib
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const scalableCapability = "tosca.capabilities.Scalable"
+
+// NodeInstance is a runtime instance of a Node Template, a Node Template has several
+// instances when it is scaled.
+type NodeInstance struct {
+	ID         string                 `yaml:"id" json:"id"`                                     // The unique name of the instance, <template>_<index>.
+	Template   string                 `yaml:"template" json:"template"`                         // The name of the Node Template.
+	Index      int                    `yaml:"index" json:"index"`                               // The index of the instance among the instances of the Node Template.
+	State      int                    `yaml:"state" json:"state"`                               // The current state of the instance (see StateInitial...).
+	Attributes map[string]interface{} `yaml:"attributes,omitempty" json:"attributes,omitempty"` // The attribute values specific to the instance.
+}
+
+// InstanceModel holds the instances of the Node Templates of a topology and
+// tracks their state through the normative lifecycle.
+// An InstanceModel is safe for concurrent use.
+type InstanceModel struct {
+	std       *ServiceTemplateDefinition
+	mu        sync.RWMutex
+	instances map[string]*NodeInstance
+}
+
+// Instantiate creates the instance model of the topology, every Node Template
+// having the default number of instances of its Scalable capability (one when
+// it has none), in the initial state.
+func (s *ServiceTemplateDefinition) Instantiate() (*InstanceModel, error) {
+	m := &InstanceModel{std: s, instances: make(map[string]*NodeInstance)}
+	for _, name := range sortedKeys(s.TopologyTemplate.NodeTemplates) {
+		lower, upper, count := s.scaling(name)
+		if count < lower || count > upper {
+			return nil, fmt.Errorf("Default instances %d of node template %q is out of [%d, %d]", count, name, lower, upper)
+		}
+		for i := 0; i < count; i++ {
+			m.add(name, i)
+		}
+	}
+	return m, nil
+}
+
+// RestoreInstances creates the instance model of the topology from previously
+// saved instances.
+func (s *ServiceTemplateDefinition) RestoreInstances(instances []NodeInstance) *InstanceModel {
+	m := &InstanceModel{std: s, instances: make(map[string]*NodeInstance, len(instances))}
+	for i := range instances {
+		inst := instances[i]
+		inst.Attributes = copyAttributes(inst.Attributes)
+		m.instances[inst.ID] = &inst
+	}
+	return m
+}
+
+// scaling returns the minimum, maximum and default number of instances of a
+// Node Template as set by its Scalable capability.
+func (s *ServiceTemplateDefinition) scaling(name string) (int, int, int) {
+	nt := s.GetNodeTemplate(name)
+	if nt == nil {
+		return 0, 0, 0
+	}
+	capName, ok := s.findCapability(nt.Refs.Type, scalableCapability)
+	if !ok {
+		return 1, 1, 1
+	}
+
+	ct := flattenHierarchy(*s).Capabilities[scalableCapability]
+	value := func(prop string, def int) int {
+		if pa, ok := nt.Capabilities[capName].Properties[prop]; ok {
+			if i, ok := toInteger(pa.Evaluate(s, name)); ok {
+				return i
+			}
+		}
+		if i, ok := toInteger(ct.Properties[prop].Default); ok {
+			return i
+		}
+		return def
+	}
+	lower := value("min_instances", 1)
+	upper := value("max_instances", 1)
+	return lower, upper, value("default_instances", lower)
+}
+
+func (m *InstanceModel) add(template string, index int) {
+	id := fmt.Sprintf("%s_%d", template, index)
+	m.instances[id] = &NodeInstance{ID: id, Template: template, Index: index, State: StateInitial}
+}
+
+// Scale sets the number of instances of a Node Template, within the bounds of its
+// Scalable capability. New instances are in the initial state, the instances with
+// the highest indexes are removed first and must be in the initial or deleted state.
+func (m *InstanceModel) Scale(template string, count int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.std.GetNodeTemplate(template) == nil {
+		return fmt.Errorf("Node template %q not found", template)
+	}
+	lower, upper, _ := m.std.scaling(template)
+	if count < lower || count > upper {
+		return fmt.Errorf("Node template %q can't be scaled to %d instances, expected [%d, %d]", template, count, lower, upper)
+	}
+
+	instances := m.templateInstances(template)
+	for _, inst := range instances[minInt(count, len(instances)):] {
+		if inst.State != StateInitial && inst.State != StateDeleted {
+			return fmt.Errorf("Instance %q can't be removed in state %s", inst.ID, StateName(inst.State))
+		}
+	}
+	for _, inst := range instances[minInt(count, len(instances)):] {
+		delete(m.instances, inst.ID)
+	}
+
+	next := 0
+	if len(instances) != 0 {
+		next = instances[len(instances)-1].Index + 1
+	}
+	for i := len(instances); i < count; i++ {
+		m.add(template, next)
+		next++
+	}
+	return nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// templateInstances returns the instances of a Node Template ordered by index
+func (m *InstanceModel) templateInstances(template string) []*NodeInstance {
+	var instances []*NodeInstance
+	for _, inst := range m.instances {
+		if inst.Template == template {
+			instances = append(instances, inst)
+		}
+	}
+	sort.Stable(byIndex(instances))
+	return instances
+}
+
+type byIndex []*NodeInstance
+
+func (b byIndex) Len() int           { return len(b) }
+func (b byIndex) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byIndex) Less(i, j int) bool { return b[i].Index < b[j].Index }
+
+// Instances returns a copy of the instances of the named Node Template, or of all
+// the instances when the name is empty, ordered by template name and index.
+func (m *InstanceModel) Instances(template string) []NodeInstance {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	templates := []string{template}
+	if template == "" {
+		templates = sortedKeys(m.std.TopologyTemplate.NodeTemplates)
+	}
+	var instances []NodeInstance
+	for _, name := range templates {
+		for _, inst := range m.templateInstances(name) {
+			cp := *inst
+			cp.Attributes = copyAttributes(inst.Attributes)
+			instances = append(instances, cp)
+		}
+	}
+	return instances
+}
+
+// Instance returns a copy of the instance with the given ID
+func (m *InstanceModel) Instance(id string) (NodeInstance, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	inst, ok := m.instances[id]
+	if !ok {
+		return NodeInstance{}, false
+	}
+	cp := *inst
+	cp.Attributes = copyAttributes(inst.Attributes)
+	return cp, true
+}
+
+// SetState moves an instance to a new state, the transition must be allowed by
+// the normative lifecycle (see IsValidTransition).
+func (m *InstanceModel) SetState(id string, state int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inst, ok := m.instances[id]
+	if !ok {
+		return fmt.Errorf("Instance %q not found", id)
+	}
+	if !IsValidTransition(inst.State, state) {
+		return fmt.Errorf("Instance %q can't go from state %s to %s", id, StateName(inst.State), StateName(state))
+	}
+	inst.State = state
+	return nil
+}
+
+// SetAttribute sets the value of an attribute of an instance, the Node Template
+// and its other instances are left untouched.
+func (m *InstanceModel) SetAttribute(id, attr string, value interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inst, ok := m.instances[id]
+	if !ok {
+		return fmt.Errorf("Instance %q not found", id)
+	}
+	if attr == "state" {
+		return fmt.Errorf("The state of instance %q must be set with SetState", id)
+	}
+	if inst.Attributes == nil {
+		inst.Attributes = make(map[string]interface{})
+	}
+	inst.Attributes[attr] = value
+	return nil
+}
+
+// GetAttribute returns the value of an attribute of an instance: its state, its
+// own value or, when it has none, the value of the attribute of its Node Template.
+func (m *InstanceModel) GetAttribute(id, attr string) (interface{}, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	inst, ok := m.instances[id]
+	if !ok {
+		return nil, false
+	}
+	switch attr {
+	case "state":
+		return StateName(inst.State), true
+	case "tosca_id":
+		return inst.ID, true
+	case "tosca_name":
+		return inst.Template, true
+	}
+	if v, ok := inst.Attributes[attr]; ok {
+		return v, true
+	}
+	if v := m.std.GetAttribute(inst.Template, attr).Evaluate(m.std, inst.Template); v != nil {
+		return v, true
+	}
+	return nil, false
+}
+
+func copyAttributes(attrs map[string]interface{}) map[string]interface{} {
+	if attrs == nil {
+		return nil
+	}
+	cp := make(map[string]interface{}, len(attrs))
+	for k, v := range attrs {
+		cp[k] = v
+	}
+	return cp
+}
@@ -0,0 +1,126 @@
+package toscalib
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func parseScalable(t *testing.T) *ServiceTemplateDefinition {
+	fname := "./tests/tosca_scalable_instances.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+	return &s
+}
+
+func TestInstantiate(t *testing.T) {
+	s := parseScalable(t)
+	m, err := s.Instantiate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(m.Instances("web_server")); n != 2 {
+		t.Errorf("expected 2 instances of web_server, got %d", n)
+	}
+	if n := len(m.Instances("")); n != 3 {
+		t.Errorf("expected 3 instances, got %d", n)
+	}
+
+	if err := m.SetAttribute("web_server_0", "private_address", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetAttribute("web_server_1", "private_address", "10.0.0.2"); err != nil {
+		t.Fatal(err)
+	}
+	for id, ip := range map[string]string{"web_server_0": "10.0.0.1", "web_server_1": "10.0.0.2"} {
+		if v, ok := m.GetAttribute(id, "private_address"); !ok || v != ip {
+			t.Errorf("expected %s to have address %s, got %v", id, ip, v)
+		}
+	}
+	if attr := s.GetAttribute("web_server", "private_address"); attr.Value != nil {
+		t.Errorf("the template attribute was modified: %v", attr.Value)
+	}
+
+	if err := m.SetState("web_server_0", StateStarted); err == nil {
+		t.Error("expected the transition from initial to started to be rejected")
+	}
+	for _, state := range []int{StateCreating, StateCreated, StateConfiguring} {
+		if err := m.SetState("web_server_0", state); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v, _ := m.GetAttribute("web_server_0", "state"); v != "configuring" {
+		t.Errorf("unexpected state %v", v)
+	}
+
+	if err := m.Scale("web_server", 5); err == nil {
+		t.Error("expected scaling above max_instances to fail")
+	}
+	if err := m.Scale("web_server", 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Instance("web_server_2"); !ok {
+		t.Error("missing instance web_server_2")
+	}
+	if err := m.Scale("web_server", 0); err == nil {
+		t.Error("expected scaling below min_instances to fail")
+	}
+	if err := m.Scale("web_server", 1); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(m.Instances("web_server")); n != 1 {
+		t.Errorf("expected 1 instance of web_server, got %d", n)
+	}
+}
+
+func TestExecutorInstances(t *testing.T) {
+	s := parseScalable(t)
+	m, err := s.Instantiate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Scale("web_server", 3)
+
+	d := &fakeDispatcher{}
+	e := &Executor{Dispatcher: d, Parallelism: 2, Instances: m}
+	if _, err := e.Run(context.Background(), s, DeployWorkflow); err != nil {
+		t.Fatal(err)
+	}
+	for _, inst := range m.Instances("") {
+		if inst.State != StateStarted {
+			t.Errorf("expected %s to be started, got %s", inst.ID, StateName(inst.State))
+		}
+	}
+	if len(d.calls) != 2 || d.calls[0].Instance != "web_app_0" {
+		t.Errorf("unexpected calls %v", d.calls)
+	}
+
+	// deploying again is not a valid transition
+	if _, err := e.Run(context.Background(), s, DeployWorkflow); err == nil {
+		t.Error("expected the second deployment to fail")
+	}
+}
+
+func TestStateTransitions(t *testing.T) {
+	if state, err := ParseState("configured"); err != nil || state != StateConfigured {
+		t.Errorf("unexpected state %v %v", state, err)
+	}
+	if _, err := ParseState("sleeping"); err == nil {
+		t.Error("expected an unknown state to be rejected")
+	}
+	if StateName(StateDeleted) != "deleted" {
+		t.Errorf("unexpected name %s", StateName(StateDeleted))
+	}
+	if !IsValidTransition(StateStopping, StateConfigured) || IsValidTransition(StateStarted, StateDeleting) {
+		t.Error("unexpected transitions")
+	}
+}
@@ -0,0 +1,274 @@
+package toscalib
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// NamespaceSeparator separates the namespace prefix of an import from the name of
+// a type it defines, ie. ns1:Server
+const NamespaceSeparator = ":"
+
+// typeSections are the sections of a document defining types, by YAML key
+var typeSections = []struct {
+	key   string
+	types func(s *ServiceTemplateDefinition) reflect.Value
+}{
+	{"artifact_types", func(s *ServiceTemplateDefinition) reflect.Value { return reflect.ValueOf(&s.ArtifactTypes).Elem() }},
+	{"data_types", func(s *ServiceTemplateDefinition) reflect.Value { return reflect.ValueOf(&s.DataTypes).Elem() }},
+	{"capability_types", func(s *ServiceTemplateDefinition) reflect.Value { return reflect.ValueOf(&s.CapabilityTypes).Elem() }},
+	{"interface_types", func(s *ServiceTemplateDefinition) reflect.Value { return reflect.ValueOf(&s.InterfaceTypes).Elem() }},
+	{"relationship_types", func(s *ServiceTemplateDefinition) reflect.Value { return reflect.ValueOf(&s.RelationshipTypes).Elem() }},
+	{"node_types", func(s *ServiceTemplateDefinition) reflect.Value { return reflect.ValueOf(&s.NodeTypes).Elem() }},
+	{"group_types", func(s *ServiceTemplateDefinition) reflect.Value { return reflect.ValueOf(&s.GroupTypes).Elem() }},
+	{"policy_types", func(s *ServiceTemplateDefinition) reflect.Value { return reflect.ValueOf(&s.PolicyTypes).Elem() }},
+}
+
+// typeReferences are the YAML keys whose values name a type, with the sections of
+// the types they may name, any section when nil
+var typeReferences = map[string][]string{
+	"type":               nil,
+	"derived_from":       nil,
+	"capability":         {"capability_types"},
+	"node":               {"node_types"},
+	"valid_source_types": {"node_types"},
+	"valid_target_types": {"capability_types"},
+	"members":            {"node_types", "group_types"},
+	"targets":            {"node_types", "group_types"},
+	"entry_schema":       {"data_types"},
+}
+
+// symbolicReferences are the type references of a topology template which may name
+// a node template, a group or a capability instead of a type
+var symbolicReferences = map[string]bool{
+	"capability": true,
+	"node":       true,
+	"members":    true,
+	"targets":    true,
+}
+
+// qualifier holds the names of an imported document needed to qualify the
+// references it makes to its own types
+type qualifier struct {
+	defined  map[string]map[string]string // The qualified names of the types defined by the document, by section key and name.
+	names    map[string]bool              // The names of the node templates, groups and capabilities of the document.
+	topology bool                         // Whether the references are made within the topology template.
+}
+
+// lookup returns the qualified name of the type named by the value of a type
+// reference, the names of the templates, groups and capabilities of the document
+// take precedence over the type names for the symbolic references
+func (q *qualifier) lookup(key, name string) (string, bool) {
+	if q.topology && symbolicReferences[key] && q.names[name] {
+		return "", false
+	}
+	sections := typeReferences[key]
+	if sections == nil {
+		for _, section := range typeSections {
+			sections = append(sections, section.key)
+		}
+	}
+	for _, section := range sections {
+		if qualified, ok := q.defined[section][name]; ok {
+			return qualified, true
+		}
+	}
+	return "", false
+}
+
+// qualify prefixes the names of the types defined in an imported document with the
+// namespace prefix of its import, and so do the references to them made within the
+// document. The references to the types defined elsewhere are left as is.
+func (s *ServiceTemplateDefinition) qualify(prefix string) {
+	q := &qualifier{defined: make(map[string]map[string]string), names: make(map[string]bool)}
+	for _, section := range typeSections {
+		types := section.types(s)
+		if types.Len() == 0 {
+			continue
+		}
+		defined := make(map[string]string)
+		qualified := reflect.MakeMap(types.Type())
+		for _, k := range types.MapKeys() {
+			name := prefix + NamespaceSeparator + k.String()
+			defined[k.String()] = name
+			qualified.SetMapIndex(reflect.ValueOf(name), types.MapIndex(k))
+		}
+		q.defined[section.key] = defined
+		types.Set(qualified)
+	}
+
+	for name, nt := range s.TopologyTemplate.NodeTemplates {
+		q.names[name] = true
+		for capability := range nt.Capabilities {
+			q.names[capability] = true
+		}
+	}
+	for name := range s.TopologyTemplate.Groups {
+		q.names[name] = true
+	}
+	for _, nt := range s.NodeTypes {
+		for capability := range nt.Capabilities {
+			q.names[capability] = true
+		}
+	}
+	for _, gt := range s.GroupTypes {
+		for capability := range gt.Capabilities {
+			q.names[capability] = true
+		}
+	}
+
+	q.references(reflect.ValueOf(s).Elem())
+
+	sources := make(map[string]SourcePosition, len(s.Sources))
+	for path, pos := range s.Sources {
+		sources[q.qualifyPath(path)] = pos
+	}
+	s.Sources = sources
+}
+
+// references replaces the type names found in the type references of v, which
+// must be addressable, by their qualified name
+func (q *qualifier) references(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			q.references(v.Elem())
+		}
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			key := strings.Split(f.Tag.Get("yaml"), ",")[0]
+			if key == "-" {
+				continue
+			}
+			if key == "topology_template" {
+				q.topology = true
+				q.references(v.Field(i))
+				q.topology = false
+				continue
+			}
+			if _, ok := typeReferences[key]; ok {
+				q.qualifyNames(key, v.Field(i))
+				continue
+			}
+			q.references(v.Field(i))
+		}
+
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			q.references(v.Index(i))
+		}
+
+	case reflect.Map:
+		// map elements are not addressable, they are updated on a copy
+		for _, k := range v.MapKeys() {
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(v.MapIndex(k))
+			q.references(e)
+			v.SetMapIndex(k, e)
+		}
+	}
+}
+
+// qualifyNames qualifies the value of a type reference, a name or a list of names
+func (q *qualifier) qualifyNames(key string, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		if name, ok := q.lookup(key, v.String()); ok {
+			v.SetString(name)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			q.qualifyNames(key, v.Index(i))
+		}
+	case reflect.Interface:
+		// the entry schema is a type name or a map holding the type name
+		switch e := v.Interface().(type) {
+		case string:
+			if name, ok := q.lookup(key, e); ok {
+				v.Set(reflect.ValueOf(name))
+			}
+		case map[interface{}]interface{}:
+			if t, ok := e["type"].(string); ok {
+				if name, ok := q.lookup(key, t); ok {
+					e["type"] = name
+				}
+			}
+		}
+	default:
+		q.references(v)
+	}
+}
+
+// qualifyPath qualifies the type name of the YAML path of an element of a type
+// section, ie. node_types.Server.properties becomes node_types.ns1:Server.properties
+func (q *qualifier) qualifyPath(path string) string {
+	for _, section := range typeSections {
+		if !strings.HasPrefix(path, section.key+".") {
+			continue
+		}
+		// the type names are dotted, the longest one matching is the type name
+		rest, match := path[len(section.key)+1:], ""
+		for name := range q.defined[section.key] {
+			if (rest == name || strings.HasPrefix(rest, name+".")) && len(name) > len(match) {
+				match = name
+			}
+		}
+		if match != "" {
+			return section.key + "." + q.defined[section.key][match] + rest[len(match):]
+		}
+	}
+	return path
+}
+
+// namespaces tracks the namespace prefixes bound by the imports of a document
+// and the imported document defining each type, to report the clashes between
+// the imports.
+type namespaces struct {
+	uris    map[string]string
+	origins map[string]string
+}
+
+func newNamespaces() *namespaces {
+	return &namespaces{uris: make(map[string]string), origins: make(map[string]string)}
+}
+
+// bind records the namespace URI bound to a prefix, a prefix can't be bound to
+// two namespaces
+func (n *namespaces) bind(im ImportDefinition) error {
+	if im.NamespacePrefix == "" {
+		return nil
+	}
+	if strings.Contains(im.NamespacePrefix, NamespaceSeparator) {
+		return fmt.Errorf("Invalid namespace prefix %q of import %q", im.NamespacePrefix, im.File)
+	}
+	if uri, ok := n.uris[im.NamespacePrefix]; ok && uri != im.NamespaceURI {
+		return fmt.Errorf("Namespace prefix %q of import %q is bound to %q and %q", im.NamespacePrefix, im.File, uri, im.NamespaceURI)
+	}
+	n.uris[im.NamespacePrefix] = im.NamespaceURI
+	return nil
+}
+
+// check verifies that the types defined by an imported document are not defined
+// differently by the documents imported before
+func (n *namespaces) check(file string, imported, std ServiceTemplateDefinition) error {
+	for _, section := range typeSections {
+		types, known := section.types(&imported), section.types(&std)
+		for _, name := range sortedKeys(types.Interface()) {
+			k := reflect.ValueOf(name)
+			if prev, ok := n.origins[section.key+"."+name]; ok {
+				if other := known.MapIndex(k); other.IsValid() && !reflect.DeepEqual(other.Interface(), types.MapIndex(k).Interface()) {
+					return fmt.Errorf("Type %q of import %q is already defined by import %q", name, file, prev)
+				}
+				continue
+			}
+			n.origins[section.key+"."+name] = file
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package toscalib
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImportNamespaces(t *testing.T) {
+	fname := "./tests/tosca_import_namespaces.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Parse(o); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"compute:mycorp.nodes.Server", "compute:mycorp.nodes.BigServer", "net:mycorp.nodes.Server"} {
+		if _, ok := s.NodeTypes[name]; !ok {
+			t.Errorf("node type %q not found", name)
+		}
+	}
+	if _, ok := s.NodeTypes["mycorp.nodes.Server"]; ok {
+		t.Error("the types of the namespaced imports must be qualified")
+	}
+
+	// the references made within the imported document are qualified too
+	big := s.NodeTypes["compute:mycorp.nodes.BigServer"]
+	if big.DerivedFrom != "compute:mycorp.nodes.Server" {
+		t.Errorf("unexpected derived_from %q", big.DerivedFrom)
+	}
+	server := s.NodeTypes["compute:mycorp.nodes.Server"]
+	if server.DerivedFrom != "tosca.nodes.Compute" {
+		t.Errorf("the normative type must not be qualified, got %q", server.DerivedFrom)
+	}
+	if c := server.Capabilities["console"]; c.Type != "compute:mycorp.capabilities.Console" {
+		t.Errorf("unexpected capability type %q", c.Type)
+	}
+	if es, ok := server.Properties["disks"].EntrySchema.(map[interface{}]interface{}); !ok || es["type"] != "compute:mycorp.datatypes.Disk" {
+		t.Errorf("unexpected entry schema %v", server.Properties["disks"].EntrySchema)
+	}
+
+	flats := flattenHierarchy(s)
+	if _, ok := flats.Nodes["compute:mycorp.nodes.BigServer"].Properties["disks"]; !ok {
+		t.Error("the namespaced type does not inherit from its parent")
+	}
+	if _, ok := flats.Nodes["net:mycorp.nodes.Server"].Properties["interfaces_count"]; !ok {
+		t.Error("the types of the two imports are mixed up")
+	}
+
+	if pos, ok := s.Position("node_types.compute:mycorp.nodes.Server.properties.flavor"); !ok || filepath.Base(pos.File) != "mycorp_servers.yaml" {
+		t.Errorf("unexpected position %v", pos)
+	}
+
+	if errs := s.Validate(); errs.HasErrors() {
+		t.Errorf("unexpected validation errors: %v", errs)
+	}
+}
+
+func TestImportNamespacedTemplates(t *testing.T) {
+	doc := `tosca_definitions_version: tosca_simple_yaml_1_0
+imports:
+  - file: tests/custom_types/mycorp_database.yaml
+    namespace_uri: http://mycorp.com/data
+    namespace_prefix: data
+`
+	var s ServiceTemplateDefinition
+	if err := s.Parse(strings.NewReader(doc)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the references to the types are qualified
+	if nt := s.TopologyTemplate.NodeTemplates["Database"]; nt.Type != "data:Database" {
+		t.Errorf("unexpected type %q", nt.Type)
+	}
+	if req := s.NodeTypes["data:Application"].Requirements[0]["database"]; req.Node != "data:Database" {
+		t.Errorf("unexpected node type %q of the requirement definition", req.Node)
+	}
+
+	// the references to the templates, groups and capabilities are not
+	app := s.TopologyTemplate.NodeTemplates["application"]
+	if req := app.Requirements[0]["database"]; req.Node != "Database" || req.Capability != "database_endpoint" {
+		t.Errorf("unexpected target %q and capability %q of the requirement", req.Node, req.Capability)
+	}
+	if g := s.TopologyTemplate.Groups["storage"]; len(g.Members) != 1 || g.Members[0] != "Database" {
+		t.Errorf("unexpected members %v", g.Members)
+	}
+}
+
+func TestImportClash(t *testing.T) {
+	fname := "./tests/invalids/test_import_clash.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err == nil {
+		t.Fatal(fname, "imports two types of the same name but it did not error out")
+	}
+	if !strings.Contains(err.Error(), `"mycorp.nodes.Server"`) || !strings.Contains(err.Error(), "mycorp_servers.yaml") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestImportNamespacePrefixRebound(t *testing.T) {
+	doc := `tosca_definitions_version: tosca_simple_yaml_1_0
+imports:
+  - file: tests/custom_types/mycorp_servers.yaml
+    namespace_uri: http://mycorp.com/compute
+    namespace_prefix: mycorp
+  - file: tests/custom_types/mycorp_network_servers.yaml
+    namespace_uri: http://mycorp.com/network
+    namespace_prefix: mycorp
+`
+	var s ServiceTemplateDefinition
+	err := s.Parse(strings.NewReader(doc))
+	if err == nil || !strings.Contains(err.Error(), `"mycorp"`) {
+		t.Errorf("expected the rebound prefix to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package toscalib
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+// NodeFilter as described in Appendix 5.4
+// A node filter definition defines criteria for selection of a TOSCA Node Template based upon the template’s property values, capabilities and capability properties.
+type NodeFilter struct {
+	Properties   []PropertyFilter   `yaml:"properties,omitempty" json:"properties,omitempty"`     // An optional sequenced list of property filters that would be used to select (filter) matching TOSCA entities (e.g., Node Template, Node Type, Capability Types, etc.) based upon their property definitions’ values.
+	Capabilities []CapabilityFilter `yaml:"capabilities,omitempty" json:"capabilities,omitempty"` // An optional sequenced list of property filters that would be used to select (filter) matching TOSCA entities (e.g., Node Template, Node Type, Capability Types, etc.) based upon their capabilities’ property definitions’ values.
+}
+
+// UnmarshalYAML is used to accept the property filters either as a sequenced list or as a map
+func (f *NodeFilter) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw struct {
+		Properties   interface{}        `yaml:"properties,omitempty"`
+		Capabilities []CapabilityFilter `yaml:"capabilities,omitempty"`
+	}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	props, err := newPropertyFilters(raw.Properties)
+	if err != nil {
+		return err
+	}
+	f.Properties = props
+	f.Capabilities = raw.Capabilities
+	return nil
+}
+
+// Matches returns true if the Node Template satisfies every property and capability
+// filter. Values that are function calls can't be evaluated without the Service
+// Template and do not match.
+func (f *NodeFilter) Matches(nt *NodeTemplate) bool {
+	return f.matches(nil, nt)
+}
+
+// matches is Matches evaluating the function calls within the Service Template
+func (f *NodeFilter) matches(s *ServiceTemplateDefinition, nt *NodeTemplate) bool {
+	ntype := nt.Refs.Type
+	if !matchProperties(s, nt, f.Properties, nt.Properties, ntype.Properties) {
+		return false
+	}
+	for _, cf := range f.Capabilities {
+		name, ok := s.findCapability(ntype, cf.Name)
+		if !ok {
+			return false
+		}
+		if !matchProperties(s, nt, cf.Properties, nt.Capabilities[name].Properties, ntype.Capabilities[name].Properties) {
+			return false
+		}
+	}
+	return true
+}
+
+// PropertyFilter as described in Appendix 5.3
+// A property filter definition defines criteria, using constraint clauses, for selection of a TOSCA entity based upon it property values.
+// A plain value is a shorthand for the equal constraint.
+type PropertyFilter struct {
+	Name        string
+	Constraints Constraints
+}
+
+// UnmarshalYAML is used to match both the single and the list of constraints notations
+func (p *PropertyFilter) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var m map[string]interface{}
+	if err := unmarshal(&m); err != nil {
+		return err
+	}
+	if len(m) != 1 {
+		return fmt.Errorf("Invalid property filter %v", m)
+	}
+	for name, v := range m {
+		pf, err := newPropertyFilter(name, v)
+		if err != nil {
+			return err
+		}
+		*p = pf
+	}
+	return nil
+}
+
+// MarshalYAML converts the filter to its list of constraints notation
+func (p PropertyFilter) MarshalYAML() (interface{}, error) {
+	return map[string]Constraints{p.Name: p.Constraints}, nil
+}
+
+// UnmarshalJSON converts JSON text to a type
+func (p *PropertyFilter) UnmarshalJSON(data []byte) error {
+	var m map[string]Constraints
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	if len(m) != 1 {
+		return fmt.Errorf("Invalid property filter %s", data)
+	}
+	for name, c := range m {
+		p.Name = name
+		p.Constraints = c
+	}
+	return nil
+}
+
+// MarshalJSON converts the filter to its list of constraints notation
+func (p PropertyFilter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]Constraints{p.Name: p.Constraints})
+}
+
+func newPropertyFilter(name string, v interface{}) (PropertyFilter, error) {
+	pf := PropertyFilter{Name: name}
+	clauses := []interface{}{v}
+	if l, ok := v.([]interface{}); ok {
+		clauses = l
+	}
+	for _, c := range clauses {
+		cc, err := toConstraintClause(c)
+		if err != nil {
+			return pf, err
+		}
+		pf.Constraints = append(pf.Constraints, cc)
+	}
+	return pf, nil
+}
+
+// newPropertyFilters accepts both the sequenced list and the map of property filters
+func newPropertyFilters(raw interface{}) ([]PropertyFilter, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	if m, ok := raw.(map[interface{}]interface{}); ok {
+		sm, _ := toStringMap(m)
+		var filters []PropertyFilter
+		for _, name := range sortedKeys(sm) {
+			pf, err := newPropertyFilter(name, sm[name])
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, pf)
+		}
+		return filters, nil
+	}
+
+	data, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var filters []PropertyFilter
+	err = yaml.Unmarshal(data, &filters)
+	return filters, err
+}
+
+// CapabilityFilter is the list of property filters applied to a capability,
+// identified by its name or its type.
+type CapabilityFilter struct {
+	Name       string
+	Properties []PropertyFilter
+}
+
+// UnmarshalYAML converts YAML text to a type
+func (c *CapabilityFilter) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var m map[string]struct {
+		Properties interface{} `yaml:"properties,omitempty"`
+	}
+	if err := unmarshal(&m); err != nil {
+		return err
+	}
+	if len(m) != 1 {
+		return fmt.Errorf("Invalid capability filter %v", m)
+	}
+	for name, v := range m {
+		props, err := newPropertyFilters(v.Properties)
+		if err != nil {
+			return err
+		}
+		c.Name = name
+		c.Properties = props
+	}
+	return nil
+}
+
+type capabilityFilterBody struct {
+	Properties []PropertyFilter `yaml:"properties,omitempty" json:"properties,omitempty"`
+}
+
+// MarshalYAML converts the filter to its TOSCA notation
+func (c CapabilityFilter) MarshalYAML() (interface{}, error) {
+	return map[string]capabilityFilterBody{c.Name: {c.Properties}}, nil
+}
+
+// UnmarshalJSON converts JSON text to a type
+func (c *CapabilityFilter) UnmarshalJSON(data []byte) error {
+	var m map[string]capabilityFilterBody
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	if len(m) != 1 {
+		return fmt.Errorf("Invalid capability filter %s", data)
+	}
+	for name, v := range m {
+		c.Name = name
+		c.Properties = v.Properties
+	}
+	return nil
+}
+
+// MarshalJSON converts the filter to its TOSCA notation
+func (c CapabilityFilter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]capabilityFilterBody{c.Name: {c.Properties}})
+}
+
+func toConstraintClause(v interface{}) (ConstraintClause, error) {
+	if m, ok := v.(map[interface{}]interface{}); ok && len(m) == 1 {
+		for op, val := range m {
+			if s, isStr := op.(string); isStr && isOperator(s) {
+				return ConstraintClause{Operator: s, Values: val}, nil
+			}
+		}
+	}
+	return ConstraintClause{Operator: "equal", Values: v}, nil
+}
+
+func matchProperties(s *ServiceTemplateDefinition, nt *NodeTemplate, filters []PropertyFilter, props map[string]PropertyAssignment, defs map[string]PropertyDefinition) bool {
+	tc := newTypeChecker(s)
+	for _, pf := range filters {
+		pa, ok := props[pf.Name]
+		if !ok || (s == nil && pa.Function != "") {
+			return false
+		}
+		v := pa.Evaluate(s, nt.Name)
+		if v == nil {
+			return false
+		}
+		// compare the values using their declared type (ie. version)
+		if def, ok := defs[pf.Name]; ok {
+			if out, errs := tc.convert(pf.Name, PropertyDefinition{Type: def.Type, EntrySchema: def.EntrySchema}, v); len(errs) == 0 {
+				v = out
+			}
+		}
+		if ok, _ := pf.Constraints.IsValid(v); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package toscalib
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestNodeFilterParse(t *testing.T) {
+	fname := "./tests/tosca_abstract_node_template_with_node_filter.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	nf := s.GetNodeTemplate("mysql_compute").NodeFilter
+	if nf == nil || len(nf.Capabilities) != 2 {
+		t.Fatal(fname, "node filter not parsed", nf)
+	}
+	host := nf.Capabilities[0]
+	if host.Name != "host" || len(host.Properties) != 2 {
+		t.Fatalf("unexpected host capability filter %v", host)
+	}
+	if pf := host.Properties[0]; pf.Name != "mem_size" || pf.Constraints[0].Operator != "greater_or_equal" {
+		t.Errorf("unexpected property filter %v", pf)
+	}
+	osf := nf.Capabilities[1]
+	if pf := osf.Properties[2]; pf.Name != "type" || pf.Constraints[0].Operator != "equal" || pf.Constraints[0].Values != "linux" {
+		t.Errorf("expected a plain value to be an equal constraint, actual %v", pf)
+	}
+}
+
+func TestNodeFilterRoundTrip(t *testing.T) {
+	data := `
+properties:
+  - db_version: { greater_or_equal: 5.5 }
+  - port: [ { greater_than: 1024 }, { less_than: 65536 } ]
+capabilities:
+  - tosca.capabilities.OperatingSystem:
+      properties:
+        - type: linux
+`
+	var nf NodeFilter
+	if err := yaml.Unmarshal([]byte(data), &nf); err != nil {
+		t.Fatal(err)
+	}
+	if len(nf.Properties) != 2 || len(nf.Properties[1].Constraints) != 2 || len(nf.Capabilities) != 1 {
+		t.Fatalf("unexpected node filter %v", nf)
+	}
+
+	out, err := yaml.Marshal(nf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromYAML NodeFilter
+	if err = yaml.Unmarshal(out, &fromYAML); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(nf, fromYAML) {
+		t.Errorf("YAML round-trip failed:\n%v\n%v", nf, fromYAML)
+	}
+
+	js, err := json.Marshal(nf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromJSON NodeFilter
+	if err = json.Unmarshal(js, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if len(fromJSON.Properties) != 2 || fromJSON.Properties[1].Name != "port" || len(fromJSON.Properties[1].Constraints) != 2 ||
+		len(fromJSON.Capabilities) != 1 || fromJSON.Capabilities[0].Name != "tosca.capabilities.OperatingSystem" {
+		t.Errorf("JSON round-trip failed: %s\n%v", js, fromJSON)
+	}
+}
+
+func TestNodeFilterMatches(t *testing.T) {
+	fname := "./tests/tosca_requirement_matching.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	nf := s.GetNodeTemplate("mysql").GetRequirement("host").Nodefilter
+	if nf == nil {
+		t.Fatal(fname, "missing node filter of mysql host requirement")
+	}
+	expected := map[string]bool{
+		"small_server": false,
+		"db_server":    true,
+		"arm_server":   false,
+	}
+	for name, want := range expected {
+		if got := nf.Matches(s.GetNodeTemplate(name)); got != want {
+			t.Errorf("%s: expected %v, actual %v", name, want, got)
+		}
+	}
+}
@@ -25,7 +25,7 @@ import "github.com/kenjones-cisco/mergo"
 // customized properties, constraints or operations which override the defaults
 // provided by its Node Type and its implementations.
 type NodeTemplate struct {
-	Name         string
+	Name         string                             `yaml:"-" json:"-"`                                         // The name of the Node Template in the topology.
 	Type         string                             `yaml:"type" json:"type"`                                   // The required name of the Node Type the Node Template is based upon.
 	Description  string                             `yaml:"description,omitempty" json:"description,omitempty"` // An optional description for the Node Template.
 	Metadata     Metadata                           `yaml:"metadata,omitempty" json:"metadata"`
@@ -36,7 +36,7 @@ type NodeTemplate struct {
 	Capabilities map[string]CapabilityAssignment    `yaml:"capabilities,omitempty" json:"-" json:"capabilities,omitempty"` // An optional list of capability assignments for the Node Template.
 	Interfaces   map[string]InterfaceDefinition     `yaml:"interfaces,omitempty" json:"-" json:"interfaces,omitempty"`     // An optional list of named interface definitions for the Node Template.
 	Artifacts    map[string]ArtifactDefinition      `yaml:"artifacts,omitempty" json:"-" json:"artifacts,omitempty"`       // An optional list of named artifact definitions for the Node Template.
-	NodeFilter   map[string]NodeFilter              `yaml:"node_filter,omitempty" json:"-" json:"node_filter,omitempty"`   // The optional filter definition that TOSCA orchestrators would use to select the correct target node.  This keyname is only valid if the directive has the value of “selectable” set.
+	NodeFilter   *NodeFilter                        `yaml:"node_filter,omitempty" json:"-" json:"node_filter,omitempty"`   // The optional filter definition that TOSCA orchestrators would use to select the correct target node.  This keyname is only valid if the directive has the value of “selectable” set.
 	Copy         string                             `yaml:"copy,omitempty" json:"copy,omitempty"`                          // The optional (symbolic) name of another node template to copy into (all keynames and values) and use as a basis for this node template.
 	Refs         struct {
 		Type NodeType `yaml:"-" json:"-"`
@@ -75,19 +75,29 @@ func (n *NodeTemplate) GetRelationshipTarget(relationshipName string) string {
 }
 
 func (n *NodeTemplate) getRequirementByRelationship(relationshipName string) *RequirementAssignment {
-	for _, req := range n.Requirements {
-		for _, r := range req {
-			if r.Relationship.Type == relationshipName {
-				return &r
+	if idx, name, ok := n.requirementByRelationship(relationshipName); ok {
+		r := n.Requirements[idx][name]
+		return &r
+	}
+	return nil
+}
+
+// requirementByRelationship returns the index and the name of the first requirement
+// of the relationship
+func (n *NodeTemplate) requirementByRelationship(relationshipName string) (int, string, bool) {
+	for i, req := range n.Requirements {
+		for name, r := range req {
+			if r.Relationship.Type == relationshipName || requirementKey(n.Name, name) == relationshipName {
+				return i, name, true
 			}
 		}
 	}
-	return nil
+	return 0, "", false
 }
 
 func (n *NodeTemplate) checkCapabilityMatch(capname string, srcType []string) bool {
-	for _, cd := range n.Refs.Type.Capabilities {
-		if cd.Type == capname {
+	for name, cd := range n.Refs.Type.Capabilities {
+		if cd.Type == capname || name == capname {
 			for _, src := range srcType {
 				if cd.IsValidSourceType(src) {
 					return true
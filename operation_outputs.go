@@ -0,0 +1,89 @@
+package toscalib
+
+import "strings"
+
+// OperationOutputs holds the outputs of the operations run on a Node or
+// Relationship Template, indexed by interface, operation and output name.
+type OperationOutputs map[string]map[string]map[string]interface{}
+
+// SetOperationOutput records the value of an output of an operation run on the
+// named Node or Relationship Template, it is then available to the
+// get_operation_output function.
+func (s *ServiceTemplateDefinition) SetOperationOutput(entity, intf, op, output string, value interface{}) {
+	if s.OperationOutputs == nil {
+		s.OperationOutputs = make(map[string]OperationOutputs)
+	}
+	if s.OperationOutputs[entity] == nil {
+		s.OperationOutputs[entity] = make(OperationOutputs)
+	}
+	if s.OperationOutputs[entity][intf] == nil {
+		s.OperationOutputs[entity][intf] = make(map[string]map[string]interface{})
+	}
+	if s.OperationOutputs[entity][intf][op] == nil {
+		s.OperationOutputs[entity][intf][op] = make(map[string]interface{})
+	}
+	s.OperationOutputs[entity][intf][op][output] = value
+}
+
+// SetOperationOutputs records all the outputs of an operation run on the named
+// Node or Relationship Template.
+func (s *ServiceTemplateDefinition) SetOperationOutputs(entity, intf, op string, outputs map[string]interface{}) {
+	for name, value := range outputs {
+		s.SetOperationOutput(entity, intf, op, name, value)
+	}
+}
+
+// GetOperationOutput retrieves the value of an output recorded for an operation
+// of the named Node or Relationship Template. The interface can be given by its
+// short name (ie. Standard) or its type (ie. tosca.interfaces.node.lifecycle.Standard).
+func (s *ServiceTemplateDefinition) GetOperationOutput(entity, intf, op, output string) (interface{}, bool) {
+	for name, ops := range s.OperationOutputs[entity] {
+		if !sameInterface(name, intf) {
+			continue
+		}
+		if v, ok := ops[op][output]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func sameInterface(a, b string) bool {
+	return a == b || strings.HasSuffix(a, "."+b) || strings.HasSuffix(b, "."+a)
+}
+
+// operationOutputEntity returns the name of the Node or Relationship Template
+// referenced by the first argument of get_operation_output.
+func (s *ServiceTemplateDefinition) operationOutputEntity(name, ctx string) string {
+	switch name {
+	case Self:
+		return ctx
+	case Source, Target, Host:
+		if nt := s.findNodeTemplate(name, ctx); nt != nil {
+			return nt.Name
+		}
+		return ""
+	}
+	return name
+}
+
+func (p *Assignment) evalOperationOutput(std *ServiceTemplateDefinition, ctx string) (interface{}, error) {
+	args := make([]string, len(p.Args))
+	for i := range p.Args {
+		arg, err := p.stringArg(i)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = arg
+	}
+	entity := std.operationOutputEntity(args[0], ctx)
+	if entity == "" {
+		return nil, p.errorf(EvalNotFound, "entity %q not found", args[0])
+	}
+	if _, ok := std.TopologyTemplate.RelationshipTemplates[entity]; !ok && std.GetNodeTemplate(entity) == nil && std.GetRelationshipSource(entity) == nil {
+		return nil, p.errorf(EvalNotFound, "entity %q not found", entity)
+	}
+	// the output is not known until the operation has run
+	v, _ := std.GetOperationOutput(entity, args[1], args[2], args[3])
+	return v, nil
+}
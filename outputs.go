@@ -0,0 +1,76 @@
+package toscalib
+
+import "fmt"
+
+// EvaluateOutputs evaluates the outputs of the topology and returns their values,
+// converted to their declared type, indexed by output name.
+// The outputs that can't be resolved, or whose value does not match their type,
+// are left out of the values and reported with the reason.
+func (s *ServiceTemplateDefinition) EvaluateOutputs() (map[string]interface{}, ValidationErrors) {
+	values := make(map[string]interface{})
+	tc := newTypeChecker(s)
+
+	var errs ValidationErrors
+	for _, name := range sortedKeys(s.TopologyTemplate.Outputs) {
+		def := s.TopologyTemplate.Outputs[name]
+		path := fmt.Sprintf("topology_template.outputs.%s.value", name)
+
+		v, err := def.Value.EvaluateE(s, "")
+		if err != nil {
+			errs = append(errs, s.newValidationError(SeverityError, path, err.Error()))
+			continue
+		}
+		if reason, ok := s.unresolved(def.Value.Assignment, "", v); ok {
+			errs = append(errs, s.newValidationError(SeverityError, path, reason))
+			continue
+		}
+		if def.Type != "" {
+			out, terrs := tc.convert(path, PropertyDefinition{Type: def.Type, EntrySchema: def.EntrySchema, Constraints: def.Constraints}, v)
+			if len(terrs) != 0 {
+				for _, e := range terrs {
+					errs = append(errs, s.newValidationError(SeverityError, e.Path, e.Message))
+				}
+				continue
+			}
+			v = out
+		}
+		values[name] = v
+	}
+	return values, errs
+}
+
+// unresolved tells if an assignment evaluating to v in the context ctx is unresolved,
+// either because it has no value or because one of the functions it is built from
+// has none (concat skips them), and explains why.
+func (s *ServiceTemplateDefinition) unresolved(a Assignment, ctx string, v interface{}) (string, bool) {
+	if a.Function == ConcatFunc || a.Function == TokenFunc {
+		for _, arg := range a.Args {
+			if pa := newAssignmentFunc(arg); pa != nil {
+				if reason, ok := s.unresolved(*pa, ctx, pa.Evaluate(s, ctx)); ok {
+					return reason, true
+				}
+			}
+		}
+	}
+	if v == nil {
+		return s.unresolvedReason(a, ctx), true
+	}
+	return "", false
+}
+
+// unresolvedReason explains why an assignment evaluates to no value
+func (s *ServiceTemplateDefinition) unresolvedReason(a Assignment, ctx string) string {
+	switch a.Function {
+	case "":
+		return "output has no value"
+
+	case GetInputFunc:
+		return fmt.Sprintf("input %q has no value", get(0, a.Args))
+
+	case GetPropFunc, GetAttrFunc:
+		if nt := s.findNodeTemplate(get(0, a.Args), ctx); nt != nil {
+			return fmt.Sprintf("%s %v of node template %q has no value", a.Function, a.Args[1:], nt.Name)
+		}
+	}
+	return fmt.Sprintf("%s %v evaluates to no value", a.Function, a.Args)
+}
@@ -0,0 +1,76 @@
+package toscalib
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEvaluateOutputs(t *testing.T) {
+	fname := "./tests/tosca_outputs.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	s.SetAttribute("server", "public_address", "203.0.113.10")
+	values, errs := s.EvaluateOutputs()
+
+	if v := values["website_url"]; v != "http://203.0.113.10:8080" {
+		t.Errorf("unexpected website_url %v", v)
+	}
+	if v := values["port"]; v != 8080 {
+		t.Errorf("unexpected port %v (%T)", v, v)
+	}
+	if len(values) != 2 {
+		t.Errorf("expected 2 resolved outputs, got %v", values)
+	}
+
+	want := map[string]string{
+		"topology_template.outputs.admin_password.value":  `get_input [admin_password]: input "admin_password" not found`,
+		"topology_template.outputs.db_address.value":      `get_attribute [database private_address]: node template "database" not found`,
+		"topology_template.outputs.private_address.value": `get_attribute [private_address] of node template "server" has no value`,
+		"topology_template.outputs.replicas.value":        "expected integer, got many",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d:\n%v", len(want), len(errs), errs)
+	}
+	for _, e := range errs {
+		if msg, ok := want[e.Path]; !ok || msg != e.Message {
+			t.Errorf("unexpected error %v", e)
+		}
+	}
+}
+
+func TestEvaluateOutputsPartialConcat(t *testing.T) {
+	fname := "./tests/tosca_outputs.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	values, errs := s.EvaluateOutputs()
+	if v, ok := values["website_url"]; ok {
+		t.Errorf("expected website_url to be unresolved, got %v", v)
+	}
+	for _, e := range errs {
+		if e.Path == "topology_template.outputs.website_url.value" {
+			if e.Message != `get_attribute [public_address] of node template "server" has no value` {
+				t.Errorf("unexpected reason %q", e.Message)
+			}
+			return
+		}
+	}
+	t.Errorf("website_url not reported in %v", errs)
+}
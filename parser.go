@@ -18,10 +18,14 @@ package toscalib
 
 import (
 	"archive/zip"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/url"
+	"path"
 	"path/filepath"
+	"strings"
 
 	"golang.org/x/tools/godoc/vfs"
 	"golang.org/x/tools/godoc/vfs/zipfs"
@@ -61,7 +65,7 @@ func (t *ServiceTemplateDefinition) ParseCsar(zipfile string) error {
 	var m meta
 	err = yaml.Unmarshal(out, &m)
 	if err != nil {
-		return err
+		return newParseError("TOSCA-Metadata/TOSCA.meta", err)
 	}
 	dirname := fmt.Sprintf("/%v", filepath.Dir(m.EntryDefinition))
 	base := filepath.Base(m.EntryDefinition)
@@ -80,39 +84,80 @@ func (t *ServiceTemplateDefinition) ParseCsar(zipfile string) error {
 	}, ParserHooks{ParsedSTD: noop}) // TODO(kenjones): Add hooks as method parameter
 }
 
-func parseImports(baseDir string, impDefs []ImportDefinition, resolver Resolver, hooks ParserHooks) (ServiceTemplateDefinition, error) {
+// importer parses the imports of a document and of the documents it imports,
+// every document is parsed once and the import cycles are reported.
+type importer struct {
+	ctx      context.Context
+	resolver ContextResolver
+	hooks    ParserHooks
+	parsed   map[string]ServiceTemplateDefinition // The imported documents merged with their own imports, by canonical location.
+	chain    []string                             // The locations of the documents being imported, the main document first.
+	keys     []string                             // The canonical locations of the chain.
+}
+
+func newImporter(ctx context.Context, source string, resolver ContextResolver, hooks ParserHooks) *importer {
+	i := &importer{ctx: ctx, resolver: resolver, hooks: hooks, parsed: make(map[string]ServiceTemplateDefinition)}
+	if source != "" {
+		i.chain, i.keys = []string{source}, []string{canonicalLocation(source)}
+	}
+	return i
+}
+
+// repositorySource is the repository a document is retrieved from, the imports of
+// the document located below the URL of the repository are retrieved from it too
+type repositorySource struct {
+	ctx context.Context // The context carrying the credential of the repository.
+	url string          // The URL of the repository, empty out of a repository.
+}
+
+// contains reports whether the location belongs to the repository
+func (r repositorySource) contains(location string) bool {
+	return r.url != "" && strings.HasPrefix(location, strings.TrimSuffix(r.url, "/")+"/")
+}
+
+// parseImports parses the imports of the document found at the parent location,
+// empty when the document is read from a reader, and retrieved from the repository
+// of the source
+func (i *importer) parseImports(parent string, source repositorySource, impDefs []ImportDefinition, repos map[string]RepositoryDefinition) (ServiceTemplateDefinition, error) {
 	var std ServiceTemplateDefinition
+	ns := newNamespaces()
 
 	for _, im := range impDefs {
+		if err := ns.bind(im); err != nil {
+			return std, err
+		}
+		// the imports qualified by a repository are retrieved from the repository
+		// declared by the importing document, the other ones are located from the
+		// importing document, and retrieved with its credential when they belong to
+		// its repository
+		repository := declaredRepository(repos, im.Repository)
 		imFilePath := im.File
-		if baseDir != "" {
-			if temp := filepath.Join(baseDir, imFilePath); isAbsLocalPath(temp) {
-				imFilePath = temp
+		imSource := repositorySource{ctx: i.ctx}
+		if repository == "" {
+			imFilePath = i.importLocation(parent, im.File)
+			if source.contains(imFilePath) {
+				imSource = source
 			}
+		} else {
+			imSource.url = repos[repository].URL
 		}
-
-		r, err := resolver(imFilePath)
+		ctx, imFilePath, err := repositoryLocation(imSource.ctx, repos, repository, imFilePath)
 		if err != nil {
 			return std, err
 		}
-
-		var tt ServiceTemplateDefinition
-		err = yaml.Unmarshal(r, &tt)
+		imSource.ctx = ctx
+		tt, err := i.parseImport(imSource, imFilePath)
 		if err != nil {
-			return std, err
-		}
-		err = hooks.ParsedSTD(imFilePath, &tt)
-		if err != nil {
-			return std, err
+			return std, undeclaredRepositoryError(im.Repository, repository, im.File, err)
 		}
 
-		if len(tt.Imports) != 0 {
-			var imptt ServiceTemplateDefinition
-			imptt, err = parseImports(baseDir, tt.Imports, resolver, hooks)
-			if err != nil {
-				return std, err
-			}
-			tt = tt.Merge(imptt)
+		// the types of a namespaced import are referenced by their qualified name,
+		// and no import may redefine a type of another import
+		if im.NamespacePrefix != "" {
+			tt.qualify(im.NamespacePrefix)
+		}
+		if err = ns.check(imFilePath, tt, std); err != nil {
+			return std, err
 		}
 
 		std = std.Merge(tt)
@@ -121,13 +166,84 @@ func parseImports(baseDir string, impDefs []ImportDefinition, resolver Resolver,
 	return std, nil
 }
 
-func (t *ServiceTemplateDefinition) parse(baseDir string, data []byte, resolver Resolver, hooks ParserHooks) error {
+// parseImport returns a copy of the imported document merged with its own imports
+func (i *importer) parseImport(source repositorySource, location string) (ServiceTemplateDefinition, error) {
+	key := canonicalLocation(location)
+	for n, k := range i.keys {
+		if k == key {
+			return ServiceTemplateDefinition{}, fmt.Errorf("Import cycle: %s", strings.Join(append(i.chain[n:], location), " -> "))
+		}
+	}
+	if tt, ok := i.parsed[key]; ok {
+		return tt.Clone(), nil
+	}
+
+	r, err := i.resolver.Resolve(source.ctx, location)
+	if err != nil {
+		return ServiceTemplateDefinition{}, err
+	}
+	var tt ServiceTemplateDefinition
+	err = yaml.Unmarshal(r, &tt)
+	if err != nil {
+		return tt, newParseError(location, err)
+	}
+	tt.Sources = indexPositions(location, r)
+	err = i.hooks.ParsedSTD(location, &tt)
+	if err != nil {
+		return tt, err
+	}
+
+	if len(tt.Imports) != 0 {
+		i.chain, i.keys = append(i.chain, location), append(i.keys, key)
+		imptt, err := i.parseImports(location, source, tt.Imports, tt.Repositories)
+		i.chain, i.keys = i.chain[:len(i.chain)-1], i.keys[:len(i.keys)-1]
+		if err != nil {
+			return tt, err
+		}
+		tt = tt.mergeImported(imptt)
+	}
+
+	i.parsed[key] = tt
+	return tt.Clone(), nil
+}
+
+// importLocation returns the location of a file imported by the document found at
+// the parent location: the relative files are located from the directory of the
+// parent, be it a local directory, a URL or a directory of a CSAR archive.
+func (i *importer) importLocation(parent, file string) string {
+	if u, err := url.Parse(file); (err == nil && len(u.Scheme) > 1) || filepath.IsAbs(file) || parent == "" {
+		return file
+	}
+
+	var location string
+	u, err := url.Parse(parent)
+	switch {
+	case err != nil || len(u.Scheme) <= 1:
+		location = filepath.Join(filepath.Dir(parent), file)
+	case u.Scheme == "file":
+		location = filepath.Join(filepath.Dir(filepath.FromSlash(u.Path)), file)
+	case u.Scheme == "csar" && strings.Contains(parent, "!"):
+		parts := strings.SplitN(parent, "!", 2)
+		return parts[0] + "!" + path.Join(path.Dir(parts[1]), filepath.ToSlash(file))
+	default:
+		ref, err := url.Parse(filepath.ToSlash(file))
+		if err != nil {
+			return file
+		}
+		return u.ResolveReference(ref).String()
+	}
+	return location
+}
+
+func (t *ServiceTemplateDefinition) parse(ctx context.Context, source string, data []byte, resolver ContextResolver, hooks ParserHooks) error {
 	var std ServiceTemplateDefinition
 	// Unmarshal the data in an interface
 	err := yaml.Unmarshal(data, &std)
 	if err != nil {
-		return err
+		return newParseError(source, err)
 	}
+	std.Sources = indexPositions(source, data)
+	version, description := std.DefinitionsVersion, std.Description
 
 	err = hooks.ParsedSTD("", &std)
 	if err != nil {
@@ -144,26 +260,37 @@ func (t *ServiceTemplateDefinition) parse(baseDir string, data []byte, resolver
 		var tt ServiceTemplateDefinition
 		err = yaml.Unmarshal(data, &tt)
 		if err != nil {
-			return err
+			return newParseError(normType, err)
 		}
+		tt.Sources = indexPositions(normType, data)
 		err = hooks.ParsedSTD(normType, &tt)
 		if err != nil {
 			return err
 		}
 
-		std = std.Merge(tt)
+		std = std.mergeImported(tt)
 	}
 
 	// Load all referenced Imports (recursively)
 	var tt ServiceTemplateDefinition
-	tt, err = parseImports(baseDir, std.Imports, resolver, hooks)
+	tt, err = newImporter(ctx, source, resolver, hooks).parseImports(source, repositorySource{ctx: ctx}, std.Imports, std.Repositories)
 	if err != nil {
 		return err
 	}
-	std = std.Merge(tt)
+	std = std.mergeImported(tt)
+
+	// the normative definitions and the imports must not override the header
+	// of the document
+	if version != "" {
+		std.DefinitionsVersion = version
+	}
+	if description != "" {
+		std.Description = description
+	}
 
 	// update the initial context with the freshly loaded context
 	*t = std
+	t.resolver = resolver
 
 	// resolve all references and inherited elements
 	t.resolve()
@@ -174,28 +301,46 @@ func (t *ServiceTemplateDefinition) parse(baseDir string, data []byte, resolver
 // ParseReader retrieves and parses a TOSCA document and loads into the structure using
 // specified Resolver function to retrieve remote imports.
 func (t *ServiceTemplateDefinition) ParseReader(r io.Reader, resolver Resolver, hooks ParserHooks) error {
+	return t.ParseReaderContext(context.Background(), r, resolver, hooks)
+}
+
+// ParseReaderContext parses a TOSCA document and loads into the structure using
+// the resolver to retrieve its imports, the parsing stops when the context is done.
+func (t *ServiceTemplateDefinition) ParseReaderContext(ctx context.Context, r io.Reader, resolver ContextResolver, hooks ParserHooks) error {
 	data, err := ioutil.ReadAll(r)
 	if err != nil {
 		return err
 	}
-	return t.parse("", data, resolver, hooks)
+	return t.parse(ctx, "", data, resolver, hooks)
 }
 
 // ParseSource retrieves and parses a TOSCA document and loads into the structure using
 // specified Resolver function to retrieve remote source or imports.
 func (t *ServiceTemplateDefinition) ParseSource(source string, resolver Resolver, hooks ParserHooks) error {
-	baseDir := ""
-	if isAbsLocalPath(source) {
-		baseDir, _ = filepath.Split(source)
-	}
-	data, err := resolver(source)
+	return t.ParseSourceContext(context.Background(), source, resolver, hooks)
+}
+
+// ParseSourceContext retrieves and parses a TOSCA document and loads into the structure
+// using the resolver to retrieve the document and its imports, the parsing stops when
+// the context is done.
+func (t *ServiceTemplateDefinition) ParseSourceContext(ctx context.Context, source string, resolver ContextResolver, hooks ParserHooks) error {
+	data, err := resolver.Resolve(ctx, source)
 	if err != nil {
 		return err
 	}
-	return t.parse(baseDir, data, resolver, hooks)
+	return t.parse(ctx, source, data, resolver, hooks)
 }
 
 // Parse a TOSCA document and fill in the structure
 func (t *ServiceTemplateDefinition) Parse(r io.Reader) error {
-	return t.ParseReader(r, defaultResolver, ParserHooks{ParsedSTD: noop})
+	return t.ParseReaderContext(context.Background(), r, DefaultResolver, ParserHooks{ParsedSTD: noop})
+}
+
+// ParseWithInputs parses a TOSCA document and sets the deployment values of its
+// inputs, see SetInputs for the problems reported about the inputs.
+func (t *ServiceTemplateDefinition) ParseWithInputs(r io.Reader, inputs map[string]interface{}) error {
+	if err := t.Parse(r); err != nil {
+		return err
+	}
+	return t.SetInputs(inputs)
 }
@@ -1,6 +1,12 @@
 package toscalib
 
 import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
@@ -20,17 +26,147 @@ func TestAbsToParseSource(t *testing.T) {
 }
 
 func TestRelativeToParseSource(t *testing.T) {
-	//testing relative local profile parsing with improper imports
+	// the imports are located from the directory of the document importing them
 	testFiles := []string{"tests/refapp/tosca_elk.yaml"}
 
-	std := &ServiceTemplateDefinition{}
 	for _, testFile := range testFiles {
-		err := std.ParseSource(testFile, defaultResolver, ParserHooks{ParsedSTD: noop})
-		if err == nil {
-			t.Error("ParseSource:: parsing relative local TOSCA profile with wrong imports, expected pathError, actual got nil")
-		} else if !os.IsNotExist(err) {
-			t.Errorf("ParseSource:: parsing relative local TOSCA profile with wrong imports, expected pathError, actual %v", err.Error())
+		std := &ServiceTemplateDefinition{}
+		if err := std.ParseSource(testFile, defaultResolver, ParserHooks{ParsedSTD: noop}); err != nil {
+			t.Errorf("ParseSource:: parsing relative local TOSCA profile, expected %v, actual %v", nil, err.Error())
+			continue
+		}
+		checkRefappImports(t, std)
+	}
+
+}
+
+// checkRefappImports verifies that the nested imports of tests/refapp/tosca_elk.yaml,
+// import2/kibana.yaml importing ../import1/collectd.yaml importing ../rsyslog.yaml,
+// are found
+func checkRefappImports(t *testing.T, std *ServiceTemplateDefinition) {
+	for _, name := range []string{"tosca.nodes.SoftwareComponent.Kibana", "tosca.nodes.SoftwareComponent.Collectd", "tosca.nodes.SoftwareComponent.Rsyslog"} {
+		if _, ok := std.NodeTypes[name]; !ok {
+			t.Errorf("node type %q not imported", name)
 		}
 	}
+}
+
+func TestRemoteRelativeImports(t *testing.T) {
+	srv := httptest.NewServer(http.FileServer(http.Dir("./tests/refapp")))
+	defer srv.Close()
+
+	std := &ServiceTemplateDefinition{}
+	if err := std.ParseSource(srv.URL+"/tosca_elk.yaml", defaultResolver, ParserHooks{ParsedSTD: noop}); err != nil {
+		t.Fatal(err)
+	}
+	checkRefappImports(t, std)
+	if pos, ok := std.Position("node_types.tosca.nodes.SoftwareComponent.Rsyslog"); !ok || pos.File != srv.URL+"/rsyslog.yaml" {
+		t.Errorf("unexpected position %v", pos)
+	}
+}
+
+func TestCsarRelativeImports(t *testing.T) {
+	f, err := ioutil.TempFile("", "toscalib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	w := zip.NewWriter(f)
+	for _, name := range []string{"tosca_elk.yaml", "paypalpizzastore_nodejs_app.yaml", "logstash.yaml", "rsyslog.yaml", "import1/collectd.yaml", "import2/elasticsearch.yaml", "import2/kibana.yaml"} {
+		data, err := ioutil.ReadFile(filepath.Join("./tests/refapp", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		zf, err := w.Create("Definitions/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zf.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	std := &ServiceTemplateDefinition{}
+	if err := std.ParseSourceContext(context.Background(), "csar:"+f.Name()+"!/Definitions/tosca_elk.yaml", DefaultResolver, ParserHooks{ParsedSTD: noop}); err != nil {
+		t.Fatal(err)
+	}
+	checkRefappImports(t, std)
+}
 
+func TestImportDiamond(t *testing.T) {
+	dir, _ := os.Getwd()
+	resolved := make(map[string]int)
+	counter := ResolverFunc(func(ctx context.Context, location string) ([]byte, error) {
+		resolved[filepath.Base(location)]++
+		return DefaultResolver.Resolve(ctx, location)
+	})
+	parsed := make(map[string]int)
+	hooks := ParserHooks{ParsedSTD: func(source string, std *ServiceTemplateDefinition) error {
+		parsed[filepath.Base(source)]++
+		return nil
+	}}
+
+	var s ServiceTemplateDefinition
+	if err := s.ParseSourceContext(context.Background(), filepath.Join(dir, "tests/imports/diamond.yaml"), counter, hooks); err != nil {
+		t.Fatal(err)
+	}
+	if resolved["diamond_base.yaml"] != 1 || parsed["diamond_base.yaml"] != 1 {
+		t.Errorf("the shared import must be parsed once, resolved %d and parsed %d times", resolved["diamond_base.yaml"], parsed["diamond_base.yaml"])
+	}
+	for _, name := range []string{"mycorp.nodes.Base", "mycorp.nodes.Left", "mycorp.nodes.Right"} {
+		if _, ok := s.NodeTypes[name]; !ok {
+			t.Errorf("node type %q not imported", name)
+		}
+	}
+}
+
+func TestImportCycle(t *testing.T) {
+	dir, _ := os.Getwd()
+	source := filepath.Join(dir, "tests/imports/cycle_a.yaml")
+
+	var s ServiceTemplateDefinition
+	err := s.ParseSource(source, defaultResolver, ParserHooks{ParsedSTD: noop})
+	if err == nil {
+		t.Fatal("expected the import cycle to be reported")
+	}
+	expected := fmt.Sprintf("Import cycle: %s -> %s -> %s", source, filepath.Join(dir, "tests/imports/cycle_b.yaml"), source)
+	if err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err)
+	}
+}
+
+func TestRelativeImportsIgnoreWorkingDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toscalib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	header := "tosca_definitions_version: tosca_simple_yaml_1_0\n"
+	files := map[string]string{
+		"lib/main.yaml": header + "imports:\n  - types.yaml\n",
+		"types.yaml":    header + "node_types:\n  mycorp.nodes.Wrong:\n    derived_from: tosca.nodes.Root\n",
+	}
+	for name, content := range files {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the resolver reads the relative locations from the temporary directory, as
+	// if it were the working directory
+	rooted := ResolverFunc(func(ctx context.Context, location string) ([]byte, error) {
+		if !filepath.IsAbs(location) {
+			location = filepath.Join(dir, location)
+		}
+		return DefaultResolver.Resolve(ctx, location)
+	})
+
+	// the types.yaml of the working directory is not the one next to main.yaml
+	var s ServiceTemplateDefinition
+	if err := s.ParseSourceContext(context.Background(), filepath.Join(dir, "lib/main.yaml"), rooted, ParserHooks{ParsedSTD: noop}); err == nil {
+		t.Errorf("expected lib/types.yaml not to be found, got the types %v", sortedKeys(s.NodeTypes))
+	}
 }
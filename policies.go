@@ -49,7 +49,7 @@ type TriggerDefinition struct {
 	Schedule     TimeInterval                   `yaml:"schedule,omitempty" json:"schedule"`
 	TargetFilter EventFilterDefinition          `yaml:"target_filter,omitempty" json:"target_filter"`
 	Condition    TriggerCondition               `yaml:"condition,omitempty" json:"condition"`
-	Action       map[string]OperationDefinition `yaml:"action" json:"action"`
+	Action       map[string]OperationDefinition `yaml:"action,omitempty" json:"action"`
 }
 
 // PolicyType provides the base structure for defining what a Policy is
@@ -59,8 +59,8 @@ type PolicyType struct {
 	Metadata    Metadata                      `yaml:"metadata,omitempty" json:"metadata"`
 	Description string                        `yaml:"description,omitempty" json:"description"`
 	Properties  map[string]PropertyDefinition `yaml:"properties,omitempty" json:"properties"`
-	Targets     []string                      `yaml:"targets" json:"targets"`
-	Triggers    map[string]TriggerDefinition  `yaml:"triggers" json:"triggers"`
+	Targets     []string                      `yaml:"targets,omitempty" json:"targets"`
+	Triggers    map[string]TriggerDefinition  `yaml:"triggers,omitempty" json:"triggers"`
 }
 
 // PolicyDefinition provides the structure for an instance of a Policy based on a PolicyType
@@ -69,8 +69,8 @@ type PolicyDefinition struct {
 	Metadata    Metadata                      `yaml:"metadata,omitempty" json:"metadata"`
 	Description string                        `yaml:"description,omitempty" json:"description"`
 	Properties  map[string]PropertyAssignment `yaml:"properties,omitempty" json:"properties"`
-	Targets     []string                      `yaml:"targets" json:"targets"`
-	Triggers    map[string]TriggerDefinition  `yaml:"triggers" json:"triggers"`
+	Targets     []string                      `yaml:"targets,omitempty" json:"targets"`
+	Triggers    map[string]TriggerDefinition  `yaml:"triggers,omitempty" json:"triggers"`
 }
 
 // IsValidTarget checks if a specified target is valid for the Policy
@@ -0,0 +1,116 @@
+package toscalib
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// SourcePosition locates an element within the source document it was parsed from.
+// File is the location given to the Resolver (empty for the main document when
+// parsed from a reader); Line and Column start at 1 and are 0 when unknown.
+type SourcePosition struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p SourcePosition) String() string {
+	s := p.File
+	if p.Line > 0 {
+		if s != "" {
+			s += ":"
+		}
+		s = fmt.Sprintf("%s%d:%d", s, p.Line, p.Column)
+	}
+	return s
+}
+
+// ParseError is returned when a document cannot be parsed, it carries the
+// source document and, when known, the position of the error.
+type ParseError struct {
+	SourcePosition
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	if pos := e.SourcePosition.String(); pos != "" {
+		return fmt.Sprintf("%s: %v", pos, e.Err)
+	}
+	return e.Err.Error()
+}
+
+var yamlErrLine = regexp.MustCompile(`line ([0-9]+)`)
+
+func newParseError(source string, err error) error {
+	pe := &ParseError{SourcePosition: SourcePosition{File: source}, Err: err}
+	if m := yamlErrLine.FindStringSubmatch(err.Error()); m != nil {
+		pe.Line, _ = strconv.Atoi(m[1])
+	}
+	return pe
+}
+
+// Position returns the position in its source document of the element identified
+// by its YAML path, for example "node_types.tosca.nodes.Compute" or
+// "topology_template.node_templates.web_app.requirements[0].host".
+func (s *ServiceTemplateDefinition) Position(path string) (SourcePosition, bool) {
+	p, ok := s.Sources[path]
+	return p, ok
+}
+
+// mergeImported merges the definitions imported by the document, the positions
+// of the elements of the document itself are kept
+func (s *ServiceTemplateDefinition) mergeImported(u ServiceTemplateDefinition) ServiceTemplateDefinition {
+	std := s.Merge(u)
+	for path, pos := range s.Sources {
+		std.Sources[path] = pos
+	}
+	return std
+}
+
+// indexPositions returns the position of every key of the mappings of a YAML
+// document, indexed by its YAML path. Sequence items are addressed with their
+// index, ie. requirements[0]. The merge keys are not indexed, nor the keys of the
+// mappings merged in or used as aliases, as they are located with their anchor.
+func indexPositions(file string, data []byte) map[string]SourcePosition {
+	index := make(map[string]SourcePosition)
+	var doc yaml3.Node
+	if err := yaml3.Unmarshal(data, &doc); err != nil {
+		// the document is decoded by the parser, which reports the errors
+		return index
+	}
+	indexNode(index, file, "", &doc)
+	return index
+}
+
+// indexNode indexes the keys of the mappings found within the node at the path
+func indexNode(index map[string]SourcePosition, file, path string, n *yaml3.Node) {
+	switch n.Kind {
+	case yaml3.DocumentNode:
+		for _, c := range n.Content {
+			indexNode(index, file, path, c)
+		}
+
+	case yaml3.SequenceNode:
+		for i, c := range n.Content {
+			indexNode(index, file, fmt.Sprintf("%s[%d]", path, i), c)
+		}
+
+	case yaml3.MappingNode:
+		// the content alternates the keys and their values
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.Kind != yaml3.ScalarNode || k.Tag == "!!merge" {
+				continue
+			}
+			kpath := k.Value
+			if path != "" {
+				kpath = path + "." + k.Value
+			}
+			index[kpath] = SourcePosition{File: file, Line: k.Line, Column: k.Column}
+			indexNode(index, file, kpath, v)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package toscalib
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPosition(t *testing.T) {
+	dir, _ := os.Getwd()
+	fname := filepath.Join(dir, "tests/tosca_web_application.yaml")
+	var s ServiceTemplateDefinition
+	err := s.ParseSource(fname, defaultResolver, ParserHooks{ParsedSTD: noop})
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	want := map[string]SourcePosition{
+		"topology_template.node_templates.web_app":                         {fname, 17, 5},
+		"topology_template.node_templates.web_app.properties.context_root": {fname, 20, 9},
+		"topology_template.node_templates.web_app.requirements[0].host":    {fname, 22, 11},
+		"topology_template.node_templates.server.capabilities.os":          {fname, 48, 9},
+	}
+	for path, pos := range want {
+		got, ok := s.Position(path)
+		if !ok {
+			t.Errorf("missing position for %s", path)
+			continue
+		}
+		if got != pos {
+			t.Errorf("%s: expected %v, actual %v", path, pos, got)
+		}
+	}
+
+	if pos, ok := s.Position("node_types.tosca.nodes.Compute"); !ok || pos.File != "node_types" {
+		t.Errorf("normative type position not recorded: %v", pos)
+	}
+	// the normative definitions share the keys of the header of the document
+	if pos, ok := s.Position("tosca_definitions_version"); !ok || pos != (SourcePosition{fname, 1, 1}) {
+		t.Errorf("the position of the document is overridden: %v", pos)
+	}
+}
+
+func TestPositionScalars(t *testing.T) {
+	fname := "./tests/tosca_positions.yaml"
+	var s ServiceTemplateDefinition
+	err := s.ParseSource(fname, defaultResolver, ParserHooks{ParsedSTD: noop})
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	want := map[string]SourcePosition{
+		"topology_template.inputs.sizes.default":                                   {fname, 10, 7},
+		"topology_template.inputs.sizes.default.small.mem":                         {fname, 11, 9},
+		`topology_template.inputs.quoted "key"`:                                    {fname, 12, 5},
+		`topology_template.inputs.quoted "key".default`:                            {fname, 14, 7},
+		"topology_template.node_templates.server.properties":                       {fname, 19, 7},
+		"topology_template.node_templates.server.properties.admin_credential.user": {fname, 21, 11},
+		"topology_template.node_templates.backup.properties.admin_port":            {fname, 26, 9},
+	}
+	for path, pos := range want {
+		got, ok := s.Position(path)
+		if !ok {
+			t.Errorf("missing position for %s", path)
+			continue
+		}
+		if got != pos {
+			t.Errorf("%s: expected %v, actual %v", path, pos, got)
+		}
+	}
+
+	// the content of the scalars is not indexed, nor the merge keys
+	for _, path := range []string{
+		"description.second",
+		"second",
+		"topology_template.inputs.sizes.mem",
+		"topology_template.inputs.sizes.default.mem",
+		"topology_template.node_templates.backup.properties.<<",
+	} {
+		if pos, ok := s.Position(path); ok {
+			t.Errorf("unexpected position for %s: %v", path, pos)
+		}
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	dir, _ := os.Getwd()
+	fname := filepath.Join(dir, "tests/invalids/test_bad_yaml.yaml")
+	var s ServiceTemplateDefinition
+	err := s.ParseSource(fname, defaultResolver, ParserHooks{ParsedSTD: noop})
+	if err == nil {
+		t.Fatal(fname, "is not valid YAML but it did not error out")
+	}
+	pe, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected a *ParseError, actual %T: %v", err, err)
+	}
+	if pe.File != fname || pe.Line == 0 {
+		t.Errorf("parse error is missing its position: %v", pe)
+	}
+	if !strings.HasPrefix(pe.Error(), fname+":") {
+		t.Errorf("parse error message does not start with its position: %v", pe)
+	}
+}
+
+func TestValidatePosition(t *testing.T) {
+	fname := "./tests/invalids/test_semantic_errors.yaml"
+	var s ServiceTemplateDefinition
+	err := s.ParseSource(fname, defaultResolver, ParserHooks{ParsedSTD: noop})
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	for _, e := range s.Validate() {
+		if e.Path == "topology_template.node_templates.web_app.requirements[0].host.node" {
+			if e.Position.File != fname || e.Position.Line != 20 {
+				t.Errorf("unexpected position for %v", e)
+			}
+			return
+		}
+	}
+	t.Error("missing error for requirement `host` of `web_app`")
+}
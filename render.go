@@ -0,0 +1,188 @@
+package toscalib
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Render returns a copy of the Service Template Definition in which the functions
+// of the property, attribute and input assignments of the node templates, the
+// relationship templates, their capabilities, requirements and interfaces, the
+// groups, the policies and the outputs are replaced by their values.
+// The assignments whose value is not known yet (ie. an attribute set at runtime,
+// an operation output or an artifact deployed on the target) are left as is, the
+// ones that can't be evaluated are left as is and reported with the reason.
+func (s *ServiceTemplateDefinition) Render() (ServiceTemplateDefinition, ValidationErrors) {
+	ns := s.Clone()
+	r := &renderer{std: s}
+
+	for _, name := range sortedKeys(ns.TopologyTemplate.NodeTemplates) {
+		nt := ns.TopologyTemplate.NodeTemplates[name]
+		path := fmt.Sprintf("topology_template.node_templates.%s", name)
+		r.properties(path+".properties", name, nt.Properties)
+		r.attributes(path+".attributes", name, nt.Attributes)
+		for _, capname := range sortedKeys(nt.Capabilities) {
+			c := nt.Capabilities[capname]
+			cpath := fmt.Sprintf("%s.capabilities.%s", path, capname)
+			r.properties(cpath+".properties", name, c.Properties)
+			r.attributes(cpath+".attributes", name, c.Attributes)
+		}
+		for i, reqs := range nt.Requirements {
+			for _, rname := range sortedKeys(reqs) {
+				rel := reqs[rname].Relationship
+				rpath := fmt.Sprintf("%s.requirements[%d].%s.relationship", path, i, rname)
+				r.properties(rpath+".properties", name, rel.Properties)
+				r.interfaces(rpath+".interfaces", name, rel.Interfaces)
+			}
+		}
+		r.interfaces(path+".interfaces", name, nt.Interfaces)
+	}
+
+	for _, name := range sortedKeys(ns.TopologyTemplate.RelationshipTemplates) {
+		rt := ns.TopologyTemplate.RelationshipTemplates[name]
+		path := fmt.Sprintf("topology_template.relationship_templates.%s", name)
+		r.properties(path+".properties", name, rt.Properties)
+		r.attributes(path+".attributes", name, rt.Attributes)
+		r.interfaces(path+".interfaces", name, rt.Interfaces)
+	}
+
+	for _, name := range sortedKeys(ns.TopologyTemplate.Groups) {
+		g := ns.TopologyTemplate.Groups[name]
+		path := fmt.Sprintf("topology_template.groups.%s", name)
+		r.properties(path+".properties", name, g.Properties)
+		r.interfaces(path+".interfaces", name, g.Interfaces)
+	}
+
+	for i, policies := range ns.TopologyTemplate.Policies {
+		for _, name := range sortedKeys(policies) {
+			path := fmt.Sprintf("topology_template.policies[%d].%s", i, name)
+			r.properties(path+".properties", name, policies[name].Properties)
+		}
+	}
+
+	for _, name := range sortedKeys(ns.TopologyTemplate.Outputs) {
+		def := ns.TopologyTemplate.Outputs[name]
+		path := fmt.Sprintf("topology_template.outputs.%s.value", name)
+		def.Value.Assignment = r.render(path, "", def.Value.Assignment)
+		ns.TopologyTemplate.Outputs[name] = def
+	}
+
+	return ns, r.errs
+}
+
+// renderer replaces the functions of the assignments of a copy of a Service
+// Template Definition by their values, evaluated against the original.
+type renderer struct {
+	std  *ServiceTemplateDefinition
+	errs ValidationErrors
+}
+
+// render returns the assignment with its value when it can be known, the functions
+// nested in the maps and lists of a value are replaced as well
+func (r *renderer) render(path, ctx string, a Assignment) Assignment {
+	if a.Function == "" {
+		if a.Value != nil {
+			a.Value = r.value(path, ctx, a.Value)
+		}
+		return a
+	}
+	if v, ok := r.evaluate(path, ctx, a); ok {
+		return Assignment{Value: v}
+	}
+	return a
+}
+
+// evaluate returns the value of a function when it can be known
+func (r *renderer) evaluate(path, ctx string, a Assignment) (interface{}, bool) {
+	if a.uses(GetArtifactFunc) {
+		return nil, false
+	}
+	v, err := a.EvaluateE(r.std, ctx)
+	if err != nil {
+		r.errs = append(r.errs, r.std.newValidationError(SeverityError, path, err.Error()))
+		return nil, false
+	}
+	if _, ok := r.std.unresolved(a, ctx, v); ok {
+		return nil, false
+	}
+	return v, true
+}
+
+// value returns a copy of a value in which the functions nested in its maps and
+// lists are replaced by their value when it can be known
+func (r *renderer) value(path, ctx string, v interface{}) interface{} {
+	if fn := newAssignmentFunc(v); fn != nil {
+		if fv, ok := r.evaluate(path, ctx, *fn); ok {
+			return fv
+		}
+		return v
+	}
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		keys := make([]string, 0, len(val))
+		names := make(map[string]interface{}, len(val))
+		for k := range val {
+			keys = append(keys, fmt.Sprint(k))
+			names[fmt.Sprint(k)] = k
+		}
+		sort.Strings(keys)
+		m := make(map[interface{}]interface{}, len(val))
+		for _, k := range keys {
+			m[names[k]] = r.value(fmt.Sprintf("%s.%s", path, k), ctx, val[names[k]])
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for _, k := range sortedKeys(val) {
+			m[k] = r.value(fmt.Sprintf("%s.%s", path, k), ctx, val[k])
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(val))
+		for i, e := range val {
+			l[i] = r.value(fmt.Sprintf("%s[%d]", path, i), ctx, e)
+		}
+		return l
+	}
+	return v
+}
+
+func (r *renderer) properties(path, ctx string, props map[string]PropertyAssignment) {
+	for _, name := range sortedKeys(props) {
+		pa := props[name]
+		pa.Assignment = r.render(fmt.Sprintf("%s.%s", path, name), ctx, pa.Assignment)
+		props[name] = pa
+	}
+}
+
+func (r *renderer) attributes(path, ctx string, attrs map[string]AttributeAssignment) {
+	for _, name := range sortedKeys(attrs) {
+		aa := attrs[name]
+		aa.Assignment = r.render(fmt.Sprintf("%s.%s", path, name), ctx, aa.Assignment)
+		attrs[name] = aa
+	}
+}
+
+func (r *renderer) interfaces(path, ctx string, intfs map[string]InterfaceDefinition) {
+	for _, iname := range sortedKeys(intfs) {
+		intf := intfs[iname]
+		ipath := fmt.Sprintf("%s.%s", path, iname)
+		r.properties(ipath+".inputs", ctx, intf.Inputs)
+		for _, oname := range sortedKeys(intf.Operations) {
+			r.properties(fmt.Sprintf("%s.%s.inputs", ipath, oname), ctx, intf.Operations[oname].Inputs)
+		}
+	}
+}
+
+// uses tells if the function, or one of the functions nested within, is fn
+func (p *Assignment) uses(fn string) bool {
+	if p.Function == fn {
+		return true
+	}
+	for _, arg := range p.Args {
+		if pa := newAssignmentFunc(arg); pa != nil && pa.uses(fn) {
+			return true
+		}
+	}
+	return false
+}
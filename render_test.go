@@ -0,0 +1,162 @@
+package toscalib
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	fname := "./tests/tosca_single_instance_wordpress.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+	if err := s.SetInputValue("db_root_pwd", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	r, errs := s.Render()
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	db := r.TopologyTemplate.NodeTemplates["mysql_database"]
+	if pa := db.Properties["name"]; pa.Function != "" || pa.Value != "wordpress" {
+		t.Errorf("property not rendered, got %v", pa)
+	}
+	if pa := db.Capabilities["database_endpoint"].Properties["port"]; pa.Function != "" || pa.Value != 3306 {
+		t.Errorf("capability property not rendered, got %v", pa)
+	}
+	dbms := r.TopologyTemplate.NodeTemplates["mysql_dbms"]
+	if pa := dbms.Properties["root_password"]; pa.Value != "secret" {
+		t.Errorf("property not rendered, got %v", pa)
+	}
+	if pa := r.TopologyTemplate.NodeTemplates["server"].Capabilities["host"].Properties["num_cpus"]; pa.Value != 1 {
+		t.Errorf("capability property not rendered, got %v", pa)
+	}
+
+	// runtime values stay symbolic
+	if out := r.TopologyTemplate.Outputs["website_url"].Value; out.Function != GetAttrFunc || out.Value != nil {
+		t.Errorf("output should not be rendered, got %v", out)
+	}
+
+	// the source template is left untouched
+	if pa := s.TopologyTemplate.NodeTemplates["mysql_database"].Properties["name"]; pa.Function != GetInputFunc {
+		t.Errorf("source template modified, got %v", pa)
+	}
+
+	s.SetAttribute("server", "private_address", "10.0.0.1")
+	r, _ = s.Render()
+	if out := r.TopologyTemplate.Outputs["website_url"].Value; out.Value != "10.0.0.1" {
+		t.Errorf("output not rendered, got %v", out)
+	}
+}
+
+func TestRenderInterfaces(t *testing.T) {
+	fname := "./tests/tosca_get_functions_semantic.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	r, _ := s.Render()
+	inputs := r.TopologyTemplate.NodeTemplates["myapp"].Interfaces["Standard"].Operations["configure"].Inputs
+	if pa := inputs["list_val"]; pa.Function != "" || pa.Value != "list_val_0" {
+		t.Errorf("operation input not rendered, got %v", pa)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	fname := "./tests/tosca_evaluation_errors.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	r, errs := s.Render()
+	found := false
+	for _, e := range errs {
+		if e.Path == "topology_template.outputs.unknown_node.value" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("unknown_node not reported in %v", errs)
+	}
+	if out := r.TopologyTemplate.Outputs["unknown_node"].Value; out.Function != GetPropFunc {
+		t.Errorf("broken output should be left as is, got %v", out)
+	}
+	// artifacts are deployed on the target
+	if out := r.TopologyTemplate.Outputs["missing_file"].Value; out.Function != GetArtifactFunc {
+		t.Errorf("get_artifact should not be rendered, got %v", out)
+	}
+	if out := r.TopologyTemplate.Outputs["first_port"].Value; out.Value != 443 {
+		t.Errorf("output not rendered, got %v", out)
+	}
+}
+
+func TestRenderNestedFunctions(t *testing.T) {
+	doc := `tosca_definitions_version: tosca_simple_yaml_1_0
+topology_template:
+  inputs:
+    port:
+      type: integer
+      default: 8080
+  node_templates:
+    server:
+      type: tosca.nodes.Compute
+      properties:
+        meta:
+          http: { get_input: port }
+          list: [ { get_input: port }, { get_attribute: [ SELF, private_address ] } ]
+`
+	var s ServiceTemplateDefinition
+	if err := s.Parse(strings.NewReader(doc)); err != nil {
+		t.Fatal(err)
+	}
+
+	r, errs := s.Render()
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	meta, ok := r.TopologyTemplate.NodeTemplates["server"].Properties["meta"].Value.(map[string]interface{})
+	if !ok {
+		t.Fatalf("unexpected value %v", r.TopologyTemplate.NodeTemplates["server"].Properties["meta"])
+	}
+	if meta["http"] != 8080 {
+		t.Errorf("nested function not rendered, got %v", meta["http"])
+	}
+	list, _ := meta["list"].([]interface{})
+	if len(list) != 2 || list[0] != 8080 {
+		t.Errorf("function of a list not rendered, got %v", meta["list"])
+	}
+	// runtime values stay symbolic
+	if len(list) == 2 && newAssignmentFunc(list[1]) == nil {
+		t.Errorf("attribute should not be rendered, got %v", list[1])
+	}
+
+	// the source template is left untouched
+	source := s.TopologyTemplate.NodeTemplates["server"].Properties["meta"].Value.(map[string]interface{})
+	if newAssignmentFunc(source["http"]) == nil {
+		t.Errorf("source template modified, got %v", source["http"])
+	}
+}
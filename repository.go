@@ -0,0 +1,171 @@
+package toscalib
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Credential is the decoded credential of a repository as described by the
+// normative data type tosca.datatypes.Credential
+type Credential struct {
+	Protocol  string            `yaml:"protocol,omitempty" json:"protocol,omitempty"`     // The optional protocol name.
+	TokenType string            `yaml:"token_type,omitempty" json:"token_type,omitempty"` // The token type, password by default.
+	Token     string            `yaml:"token" json:"token"`                               // The token used as a credential for authorization or access to a networked resource.
+	Keys      map[string]string `yaml:"keys,omitempty" json:"keys,omitempty"`             // The optional list of protocol-specific keys or assertions.
+	User      string            `yaml:"user,omitempty" json:"user,omitempty"`             // The optional user (name or ID) used for non-token based credentials.
+}
+
+// GetCredential decodes the credential of the repository, ok is false when the
+// repository has none
+func (r *RepositoryDefinition) GetCredential() (Credential, bool, error) {
+	cred := Credential{TokenType: "password"}
+	if r.Credential == nil {
+		return cred, false, nil
+	}
+	data, err := yaml.Marshal(r.Credential)
+	if err == nil {
+		err = yaml.Unmarshal(data, &cred)
+	}
+	if err != nil {
+		return cred, false, fmt.Errorf("Invalid credential: %v", err)
+	}
+	return cred, true, nil
+}
+
+// CredentialProvider applies a credential to a request made to a repository
+type CredentialProvider func(req *http.Request, cred Credential) error
+
+var (
+	credentialsMu sync.RWMutex
+	credentials   = map[string]CredentialProvider{
+		"password":   basicAuth,
+		"basic_auth": basicAuth,
+		"bearer":     bearerToken,
+		"token":      bearerToken,
+	}
+)
+
+// RegisterCredentialProvider sets the provider applying the credentials of the
+// given token type, ie. to get the token from a secret store. The credentials of
+// an unknown token type are sent in the header named after the token type, as
+// the X-Auth-Token of the specification example.
+func RegisterCredentialProvider(tokenType string, p CredentialProvider) {
+	credentialsMu.Lock()
+	defer credentialsMu.Unlock()
+	credentials[tokenType] = p
+}
+
+func basicAuth(req *http.Request, cred Credential) error {
+	req.SetBasicAuth(cred.User, cred.Token)
+	return nil
+}
+
+func bearerToken(req *http.Request, cred Credential) error {
+	req.Header.Set("Authorization", "Bearer "+cred.Token)
+	return nil
+}
+
+func authorize(req *http.Request, cred Credential) error {
+	credentialsMu.RLock()
+	p, ok := credentials[cred.TokenType]
+	credentialsMu.RUnlock()
+	if !ok {
+		req.Header.Set(cred.TokenType, cred.Token)
+		return nil
+	}
+	return p(req, cred)
+}
+
+// Location returns the location of a file of the repository
+func (r *RepositoryDefinition) Location(file string) string {
+	u, err := url.Parse(r.URL)
+	if err != nil || u.Scheme == "" || u.Scheme == "file" {
+		dir := r.URL
+		if err == nil && u.Scheme == "file" {
+			dir = u.Path
+		}
+		return filepath.Join(dir, filepath.FromSlash(file))
+	}
+	return strings.TrimSuffix(r.URL, "/") + "/" + strings.TrimPrefix(file, "/")
+}
+
+// Fetch retrieves a file of the repository with the resolver, the context carries
+// the credential of the repository (see CredentialFromContext).
+func (r *RepositoryDefinition) Fetch(ctx context.Context, file string, resolver ContextResolver) ([]byte, error) {
+	ctx, err := r.withCredential(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return resolver.Resolve(ctx, r.Location(file))
+}
+
+// withCredential returns the context carrying the credential of the repository
+func (r *RepositoryDefinition) withCredential(ctx context.Context) (context.Context, error) {
+	cred, ok, err := r.GetCredential()
+	if err != nil || !ok {
+		return ctx, err
+	}
+	return WithCredential(ctx, cred), nil
+}
+
+// declaredRepository returns the repository when it is declared. The documents
+// written before the repositories were supported may name repositories they do not
+// declare, their files are then retrieved as written.
+func declaredRepository(repos map[string]RepositoryDefinition, repository string) string {
+	if _, ok := repos[repository]; !ok {
+		return ""
+	}
+	return repository
+}
+
+// undeclaredRepositoryError reports the repository that was not declared when the
+// file retrieved as written could not be found
+func undeclaredRepositoryError(repository, declared, file string, err error) error {
+	if repository == declared {
+		return err
+	}
+	return fmt.Errorf("Repository %q of %q is not defined and the file could not be retrieved as written: %v", repository, file, err)
+}
+
+// fetchRepositoryFile retrieves the file of a repository of the Service Template
+// with the resolver it was parsed with, so that the layers of the resolver apply
+// to the artifacts too. The context of the parsing may be done by then, the file
+// is retrieved with a context of its own.
+func (s *ServiceTemplateDefinition) fetchRepositoryFile(repository, file string) ([]byte, error) {
+	resolver := s.resolver
+	if resolver == nil {
+		resolver = DefaultResolver
+	}
+	return repositoryFile(context.Background(), s.Repositories, repository, file, resolver)
+}
+
+// repositoryLocation returns the location of the file of an import or an artifact,
+// within its repository when it has one, and the context to retrieve it with
+func repositoryLocation(ctx context.Context, repos map[string]RepositoryDefinition, repository, file string) (context.Context, string, error) {
+	if repository == "" {
+		return ctx, file, nil
+	}
+	repo, ok := repos[repository]
+	if !ok {
+		return ctx, file, fmt.Errorf("Repository %q of %q is not defined", repository, file)
+	}
+	ctx, err := repo.withCredential(ctx)
+	return ctx, repo.Location(file), err
+}
+
+// repositoryFile retrieves the file of an import or an artifact, from its repository
+// when it has one
+func repositoryFile(ctx context.Context, repos map[string]RepositoryDefinition, repository, file string, resolver ContextResolver) ([]byte, error) {
+	ctx, location, err := repositoryLocation(ctx, repos, repository, file)
+	if err != nil {
+		return nil, err
+	}
+	return resolver.Resolve(ctx, location)
+}
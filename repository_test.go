@@ -0,0 +1,241 @@
+package toscalib
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// newRepositoryServer serves the custom types to the basic auth user deploy,
+// and the scripts to the X-Auth-Token and vault tokens
+func newRepositoryServer(t *testing.T) *httptest.Server {
+	types, err := ioutil.ReadFile("./tests/custom_types/mycorp_servers.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/types/mycorp_servers.yaml", func(w http.ResponseWriter, r *http.Request) {
+		if user, pwd, ok := r.BasicAuth(); !ok || user != "deploy" || pwd != "s3cret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write(types)
+	})
+	mux.HandleFunc("/scripts/install.sh", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth-Token") != "604bbe45ac7143a79e14f3158df67091" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "#!/bin/sh\necho install\n")
+	})
+	mux.HandleFunc("/vault/configure.sh", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "secret/scripts:token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "#!/bin/sh\necho configure\n")
+	})
+	return httptest.NewServer(mux)
+}
+
+const repositoryTemplate = `tosca_definitions_version: tosca_simple_yaml_1_0
+
+repositories:
+  types:
+    url: %[1]s/types
+    credential:
+      user: deploy
+      token_type: basic_auth
+      token: %[2]s
+  scripts:
+    url: %[1]s/scripts/
+    credential:
+      token_type: X-Auth-Token
+      token: 604bbe45ac7143a79e14f3158df67091
+  vault:
+    url: %[1]s/vault
+    credential:
+      token_type: vault
+      token: ignored
+      keys:
+        path: secret/scripts
+
+imports:
+  - file: mycorp_servers.yaml
+    repository: types
+
+topology_template:
+  node_templates:
+    server:
+      type: mycorp.nodes.Server
+      artifacts:
+        install:
+          file: install.sh
+          type: tosca.artifacts.Implementation.Bash
+          repository: scripts
+        configure:
+          file: configure.sh
+          type: tosca.artifacts.Implementation.Bash
+          repository: vault
+`
+
+func TestRepositoryImports(t *testing.T) {
+	srv := newRepositoryServer(t)
+	defer srv.Close()
+
+	var s ServiceTemplateDefinition
+	if err := s.Parse(strings.NewReader(fmt.Sprintf(repositoryTemplate, srv.URL, "s3cret"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.NodeTypes["mycorp.nodes.Server"]; !ok {
+		t.Fatal("the type of the repository was not imported")
+	}
+	if pos, ok := s.Position("node_types.mycorp.nodes.Server"); !ok || pos.File != srv.URL+"/types/mycorp_servers.yaml" {
+		t.Errorf("unexpected position %v", pos)
+	}
+
+	var bad ServiceTemplateDefinition
+	err := bad.Parse(strings.NewReader(fmt.Sprintf(repositoryTemplate, srv.URL, "wrong")))
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected the wrong credential to be rejected, got %v", err)
+	}
+
+	doc := "tosca_definitions_version: tosca_simple_yaml_1_0\nimports:\n  - file: types.yaml\n    repository: unknown\n"
+	if err := bad.Parse(strings.NewReader(doc)); err == nil || !strings.Contains(err.Error(), `"unknown"`) {
+		t.Errorf("expected the undefined repository to be reported, got %v", err)
+	}
+}
+
+func TestRepositoryArtifacts(t *testing.T) {
+	srv := newRepositoryServer(t)
+	defer srv.Close()
+
+	RegisterCredentialProvider("vault", func(req *http.Request, cred Credential) error {
+		// a real provider would read the token from the secret store
+		req.Header.Set("X-Vault-Token", cred.Keys["path"]+":token")
+		return nil
+	})
+	defer func() {
+		credentialsMu.Lock()
+		delete(credentials, "vault")
+		credentialsMu.Unlock()
+	}()
+
+	var s ServiceTemplateDefinition
+	if err := s.Parse(strings.NewReader(fmt.Sprintf(repositoryTemplate, srv.URL, "s3cret"))); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "toscalib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, expected := range map[string]string{"install": "echo install", "configure": "echo configure"} {
+		a := Assignment{Function: GetArtifactFunc, Args: []interface{}{"server", name, dir}}
+		v, err := a.EvaluateE(&s, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadFile(fmt.Sprint(v))
+		if err != nil || !strings.Contains(string(data), expected) {
+			t.Errorf("unexpected artifact %s: %q (%v)", name, data, err)
+		}
+	}
+}
+
+func TestUndefinedRepository(t *testing.T) {
+	fname := "./tests/invalids/test_undefined_repository.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err == nil {
+		t.Fatal(fname, "imports a file of an undefined repository but it did not error out")
+	}
+	if !strings.Contains(err.Error(), `Repository "mycorp_types"`) || !strings.Contains(err.Error(), "missing_types.yaml") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestRepositoryNestedImports(t *testing.T) {
+	types, err := ioutil.ReadFile("./tests/custom_types/mycorp_servers.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := "tosca_definitions_version: tosca_simple_yaml_1_0\n"
+	files := map[string]string{
+		"/types/all.yaml":            header + "imports:\n  - mycorp_servers.yaml\n  - ../public/network.yaml\n",
+		"/types/mycorp_servers.yaml": string(types),
+		"/public/network.yaml":       header + "node_types:\n  mycorp.nodes.Router:\n    derived_from: tosca.nodes.Root\n",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _, ok := r.BasicAuth()
+		// the credential of the repository is only sent to the repository
+		if strings.HasPrefix(r.URL.Path, "/types/") != ok {
+			http.Error(w, "unexpected credential", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, files[r.URL.Path])
+	}))
+	defer srv.Close()
+
+	doc := fmt.Sprintf(`tosca_definitions_version: tosca_simple_yaml_1_0
+repositories:
+  types:
+    url: %s/types
+    credential:
+      user: deploy
+      token_type: basic_auth
+      token: s3cret
+imports:
+  - file: all.yaml
+    repository: types
+`, srv.URL)
+	var s ServiceTemplateDefinition
+	if err := s.Parse(strings.NewReader(doc)); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"mycorp.nodes.Server", "mycorp.nodes.Router"} {
+		if _, ok := s.NodeTypes[name]; !ok {
+			t.Errorf("node type %q not imported", name)
+		}
+	}
+}
+
+func TestRepositoryArtifactsResolver(t *testing.T) {
+	srv := newRepositoryServer(t)
+	defer srv.Close()
+
+	// the artifacts are retrieved through the layers of the parsing resolver, once
+	// the context of the parsing is done
+	doc := strings.Replace(fmt.Sprintf(repositoryTemplate, srv.URL, "s3cret"), srv.URL+"/scripts/", "https://scripts.example.com/", 1)
+	r := Chain(DefaultResolver, Rewrite(RewriteRule{Prefix: "https://scripts.example.com/", Replacement: srv.URL + "/scripts/"}))
+	ctx, cancel := context.WithCancel(context.Background())
+	var s ServiceTemplateDefinition
+	if err := s.ParseReaderContext(ctx, strings.NewReader(doc), r, ParserHooks{ParsedSTD: noop}); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	dir, err := ioutil.TempDir("", "toscalib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := Assignment{Function: GetArtifactFunc, Args: []interface{}{"server", "install", dir}}
+	v, err := a.EvaluateE(&s, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(fmt.Sprint(v)); err != nil || !strings.Contains(string(data), "echo install") {
+		t.Errorf("unexpected artifact %q (%v)", data, err)
+	}
+}
@@ -0,0 +1,319 @@
+package toscalib
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RequirementBinding associates a requirement of a Node Template with the Node
+// Template, and its capability, selected to fulfil it.
+type RequirementBinding struct {
+	Source       string // The name of the Node Template declaring the requirement.
+	Requirement  string // The name of the requirement.
+	Index        int    // The index of the requirement within the requirements of the source.
+	Target       string // The name of the Node Template fulfilling the requirement.
+	Capability   string // The name of the capability of the target fulfilling the requirement, if any.
+	Relationship string // The Relationship Type or Template of the requirement.
+}
+
+type requirementMatcher struct {
+	std      *ServiceTemplateDefinition
+	ft       flatTypes
+	bindings []RequirementBinding
+	errs     ValidationErrors
+}
+
+func (m *requirementMatcher) add(path, format string, args ...interface{}) {
+	m.errs = append(m.errs, m.std.newValidationError(SeverityError, path, fmt.Sprintf(format, args...)))
+}
+
+// MatchRequirements selects the Node Template fulfilling each requirement of the
+// topology. A requirement naming a Node Template is bound to it, otherwise the
+// Node Templates are matched against the node type, the capability type, the
+// valid_source_types of the capability, the valid_target_types of the relationship
+// and the node_filter of the requirement. Requirements inherited from the node type
+// are only matched when their occurrences make them mandatory.
+// The requirements fulfilled by none or several Node Templates are reported, as well
+// as the requirements and capabilities used more than their occurrences allow.
+func (s *ServiceTemplateDefinition) MatchRequirements() ([]RequirementBinding, ValidationErrors) {
+	m := &requirementMatcher{std: s, ft: flattenHierarchy(*s)}
+	for _, name := range sortedKeys(s.TopologyTemplate.NodeTemplates) {
+		m.matchNodeTemplate(name, s.TopologyTemplate.NodeTemplates[name])
+	}
+	m.checkCapabilityOccurrences()
+
+	if len(m.errs) == 0 {
+		return m.bindings, nil
+	}
+	sort.Stable(byPath(m.errs))
+	return m.bindings, m.errs
+}
+
+// BindRequirements matches the requirements of the topology (see MatchRequirements)
+// and sets the selected Node Template, and capability, on each of them.
+func (s *ServiceTemplateDefinition) BindRequirements() error {
+	bindings, errs := s.MatchRequirements()
+	for _, b := range bindings {
+		nt := s.TopologyTemplate.NodeTemplates[b.Source]
+		req := nt.Requirements[b.Index][b.Requirement]
+		req.Node = b.Target
+		if b.Capability != "" {
+			req.Capability = b.Capability
+		}
+		nt.Requirements[b.Index][b.Requirement] = req
+		s.TopologyTemplate.NodeTemplates[b.Source] = nt
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// matchRequirement returns the name of the Node Template fulfilling the requirement,
+// found at the index of the requirements of the named Node Template, or an empty
+// string.
+func (s *ServiceTemplateDefinition) matchRequirement(source string, idx int, name string) string {
+	nt := s.GetNodeTemplate(source)
+	if nt == nil || idx >= len(nt.Requirements) {
+		return ""
+	}
+	req, ok := nt.Requirements[idx][name]
+	if !ok {
+		return ""
+	}
+	m := &requirementMatcher{std: s, ft: s.flattened()}
+	ntype, ok := m.ft.Nodes[nt.Type]
+	if !ok {
+		return ""
+	}
+	m.matchRequirement("", source, idx, name, req, ntype)
+	if len(m.bindings) == 1 {
+		return m.bindings[0].Target
+	}
+	return ""
+}
+
+func (m *requirementMatcher) matchNodeTemplate(name string, nt NodeTemplate) {
+	ntype, ok := m.ft.Nodes[nt.Type]
+	if !ok {
+		// unknown types are reported by Validate
+		return
+	}
+
+	counts := make(map[string]int)
+	for i, reqs := range nt.Requirements {
+		for _, rname := range sortedKeys(reqs) {
+			counts[rname]++
+			path := fmt.Sprintf("topology_template.node_templates.%s.requirements[%d].%s", name, i, rname)
+			m.matchRequirement(path, name, i, rname, reqs[rname], ntype)
+		}
+	}
+
+	for _, rname := range sortedKeys(counts) {
+		rd := ntype.getRequirement(rname)
+		lower, upper := occurrences(rd.Occurrences)
+		path := fmt.Sprintf("topology_template.node_templates.%s.requirements", name)
+		if counts[rname] < lower {
+			m.add(path, "requirement %q must occur at least %d times", rname, lower)
+		}
+		if counts[rname] > upper {
+			m.add(path, "requirement %q must occur at most %d times", rname, upper)
+		}
+	}
+}
+
+func (m *requirementMatcher) matchRequirement(path, source string, idx int, name string, req RequirementAssignment, ntype NodeType) {
+	rd := ntype.getRequirement(name)
+	binding := RequirementBinding{
+		Source:       source,
+		Requirement:  name,
+		Index:        idx,
+		Relationship: req.Relationship.Type,
+	}
+
+	if target := m.std.GetNodeTemplate(req.Node); target != nil {
+		binding.Target = target.Name
+		if req.Capability != "" {
+			capName, ok := m.std.findCapability(m.ft.Nodes[target.Type], req.Capability)
+			if !ok {
+				m.add(path, "node template %q does not provide capability %q", target.Name, req.Capability)
+				return
+			}
+			binding.Capability = capName
+		}
+		m.bindings = append(m.bindings, binding)
+		return
+	}
+
+	// requirements inherited as-is from the node type are optional unless
+	// their occurrences require them.
+	inherited := req.Nodefilter == nil && req.Node == rd.Node && req.Capability == rd.Capability
+	if lower, _ := occurrences(rd.Occurrences); inherited && lower == 0 {
+		return
+	}
+
+	var candidates []RequirementBinding
+	for _, tname := range sortedKeys(m.std.TopologyTemplate.NodeTemplates) {
+		if tname == source {
+			continue
+		}
+		target := m.std.TopologyTemplate.NodeTemplates[tname]
+		if capName, ok := m.fulfils(source, req, &target); ok {
+			b := binding
+			b.Target = tname
+			b.Capability = capName
+			candidates = append(candidates, b)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		m.add(path, "no node template satisfies requirement %q", name)
+	case 1:
+		m.bindings = append(m.bindings, candidates[0])
+	default:
+		names := make([]string, len(candidates))
+		for i, c := range candidates {
+			names[i] = c.Target
+		}
+		m.add(path, "requirement %q is ambiguous, it is satisfied by %s", name, strings.Join(names, ", "))
+	}
+}
+
+// fulfils reports whether the target can fulfil the requirement, and returns the
+// name of the matching capability of the target.
+func (m *requirementMatcher) fulfils(source string, req RequirementAssignment, target *NodeTemplate) (string, bool) {
+	ttype, ok := m.ft.Nodes[target.Type]
+	if !ok {
+		return "", false
+	}
+
+	if req.Node != "" && !contains(m.std.nodeTypeHierarchy(target.Type), req.Node) {
+		return "", false
+	}
+
+	capName := ""
+	if req.Capability != "" {
+		if capName, ok = m.std.findCapability(ttype, req.Capability); !ok {
+			return "", false
+		}
+		cd := ttype.Capabilities[capName]
+		valid := false
+		for _, src := range m.std.nodeTypeHierarchy(m.std.TopologyTemplate.NodeTemplates[source].Type) {
+			if cd.IsValidSourceType(src) {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return "", false
+		}
+	}
+
+	if !m.isValidTarget(req.Relationship.Type, target, ttype, capName) {
+		return "", false
+	}
+
+	if req.Nodefilter != nil && !req.Nodefilter.matches(m.std, target) {
+		return "", false
+	}
+	return capName, true
+}
+
+// isValidTarget checks the valid_target_types of the relationship against the
+// type of the target capability, or of any of its capabilities when none was
+// requested, and the type of the target itself.
+func (m *requirementMatcher) isValidTarget(relationship string, target *NodeTemplate, ttype NodeType, capName string) bool {
+	if rt, ok := m.std.TopologyTemplate.RelationshipTemplates[relationship]; ok {
+		relationship = rt.Type
+	}
+	rtype, ok := m.ft.Relationships[relationship]
+	if !ok || len(rtype.ValidTarget) == 0 {
+		return true
+	}
+
+	types := m.std.nodeTypeHierarchy(target.Type)
+	for _, name := range sortedKeys(ttype.Capabilities) {
+		if capName == "" || capName == name {
+			types = append(types, ttype.Capabilities[name].Type)
+			types = append(types, m.std.capabilityTypeHierarchy(ttype.Capabilities[name].Type)...)
+		}
+	}
+	for _, t := range types {
+		if rtype.IsValidTarget(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCapabilityOccurrences reports the capabilities bound to more requirements
+// than allowed by their occurrences.
+func (m *requirementMatcher) checkCapabilityOccurrences() {
+	counts := make(map[string]int)
+	for _, b := range m.bindings {
+		if b.Capability == "" {
+			continue
+		}
+		key := b.Target + "." + b.Capability
+		counts[key]++
+
+		nt := m.std.TopologyTemplate.NodeTemplates[b.Target]
+		cd := m.ft.Nodes[nt.Type].Capabilities[b.Capability]
+		if len(cd.Occurrences) != 2 {
+			continue
+		}
+		_, upper := occurrences([]interface{}{cd.Occurrences[0], cd.Occurrences[1]})
+		if counts[key] == upper+1 {
+			path := fmt.Sprintf("topology_template.node_templates.%s.requirements[%d].%s", b.Source, b.Index, b.Requirement)
+			m.add(path, "capability %q of node template %q can fulfil at most %d requirements", b.Capability, b.Target, upper)
+		}
+	}
+}
+
+// occurrences returns the bounds of a requirement or capability occurrences,
+// which default to [1, 1].
+func occurrences(r ToscaRange) (int, int) {
+	bounds := toList(r)
+	if len(bounds) != 2 {
+		return 1, 1
+	}
+	lower, ok := toInteger(bounds[0])
+	if !ok {
+		lower = 1
+	}
+	if s, isStr := bounds[1].(string); isStr && s == "UNBOUNDED" {
+		return lower, int(UNBOUNDED)
+	}
+	upper, ok := toInteger(bounds[1])
+	if !ok {
+		upper = lower
+	}
+	return lower, upper
+}
+
+// findCapability returns the name of the capability of the node type matching
+// the given capability name or type (including the derived types, which are only
+// known when the Service Template is given).
+func (s *ServiceTemplateDefinition) findCapability(ntype NodeType, capability string) (string, bool) {
+	if _, ok := ntype.Capabilities[capability]; ok {
+		return capability, true
+	}
+	for _, name := range sortedKeys(ntype.Capabilities) {
+		cd := ntype.Capabilities[name]
+		if cd.Type == capability || (s != nil && contains(s.capabilityTypeHierarchy(cd.Type), capability)) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
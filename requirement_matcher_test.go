@@ -0,0 +1,120 @@
+package toscalib
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMatchRequirements(t *testing.T) {
+	fname := "./tests/tosca_requirement_matching.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	if host := s.findHostNode("mysql"); host == nil || host.Name != "db_server" {
+		t.Errorf("expected mysql to be hosted on db_server, actual %v", host)
+	}
+
+	if err = s.BindRequirements(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][2]string{
+		"my_app":    {"database_endpoint", "app_db"},
+		"mysql":     {"host", "db_server"},
+		"legacy_db": {"host", "mysql"},
+		"app_db":    {"host", "mysql"},
+	}
+	for node, e := range expected {
+		nt := s.GetNodeTemplate(node)
+		if nt == nil {
+			t.Fatal(fname, "missing NodeTemplate", node)
+		}
+		if req := nt.GetRequirement(e[0]); req == nil || req.Node != e[1] {
+			t.Errorf("%s requirement %s: expected %s, actual %v", node, e[0], e[1], req)
+		}
+	}
+
+	if host := s.findHostNode("mysql"); host == nil || host.Name != "db_server" {
+		t.Errorf("expected mysql to be hosted on db_server, actual %v", host)
+	}
+}
+
+func TestMatchRequirementsErrors(t *testing.T) {
+	fname := "./tests/invalids/test_requirement_errors.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	_, errs := s.MatchRequirements()
+	want := []string{
+		"topology_template.node_templates.any_app.requirements[0].database_endpoint",
+		"topology_template.node_templates.future_app.requirements[0].database_endpoint",
+		"topology_template.node_templates.greedy_app.requirements",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, actual %d:\n%v", len(want), len(errs), errs)
+	}
+	for i, path := range want {
+		if errs[i].Path != path {
+			t.Errorf("expected error on %s, actual %v", path, errs[i])
+		}
+	}
+}
+
+func TestMatchRequirementByIndex(t *testing.T) {
+	doc := `tosca_definitions_version: tosca_simple_yaml_1_0
+node_types:
+  mycorp.nodes.Application:
+    derived_from: tosca.nodes.Root
+    requirements:
+      - cache:
+          capability: tosca.capabilities.Endpoint
+          occurrences: [ 0, 1 ]
+      - backend:
+          capability: tosca.capabilities.Endpoint.Database
+topology_template:
+  node_templates:
+    app:
+      type: mycorp.nodes.Application
+      requirements:
+        - cache:
+            capability: tosca.capabilities.Endpoint
+        - backend:
+            capability: tosca.capabilities.Endpoint
+    db:
+      type: tosca.nodes.Database
+      properties:
+        name: orders
+`
+	var s ServiceTemplateDefinition
+	if err := s.Parse(strings.NewReader(doc)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the two requirements are assigned alike, the optional cache is left unbound
+	if target := s.matchRequirement("app", 0, "cache"); target != "" {
+		t.Errorf("expected the cache not to be bound, actual %q", target)
+	}
+	if target := s.matchRequirement("app", 1, "backend"); target != "db" {
+		t.Errorf("expected the backend to be bound to db, actual %q", target)
+	}
+	if target := s.matchRequirement("app", 2, "backend"); target != "" {
+		t.Errorf("expected no requirement at index 2, actual %q", target)
+	}
+}
@@ -84,7 +84,7 @@ func (r *RequirementDefinition) UnmarshalYAML(unmarshal func(interface{}) error)
 type RequirementRelationship struct {
 	Type       string                         `yaml:"type" json:"type"`                                 // The optional reserved keyname used to provide the name of the Relationship Type for the requirement assignment’s relationship keyname.
 	Interfaces map[string]InterfaceDefinition `yaml:"interfaces,omitempty" json:"interfaces,omitempty"` // The optional reserved keyname used to reference declared (named) interface definitions of the corresponding Relationship Type in order to provide Property assignments for these interfaces or operations of these interfaces.
-	Properties map[string]PropertyAssignment  `yaml:"properties" json:"properties"`                     // The optional list property definitions that comprise the schema for a complex Data Type in TOSCA.
+	Properties map[string]PropertyAssignment  `yaml:"properties,omitempty" json:"properties"`           // The optional list property definitions that comprise the schema for a complex Data Type in TOSCA.
 }
 
 // UnmarshalYAML is used to match both Simple Notation Example and Full Notation Example
@@ -120,7 +120,7 @@ type RequirementAssignment struct {
 	Node string `yaml:"node,omitempty" json:"node,omitempty"` /* The optional reserved keyname used to identify the target node of a relationship.  specifically, it is used to provide either a:
 	   -  Node Template name that can fulfil the target node requirement.
 	   - Node Type name that the provider will use to select a type-compatible node template to fulfil the requirement at runtime.  */
-	Nodefilter NodeFilter `yaml:"node_filter,omitempty" json:"node_filter,omitempty"` // The optional filter definition that TOSCA orchestrators or providers would use to select a type-compatible target node that can fulfill the associated abstract requirement at runtime.o
+	Nodefilter *NodeFilter `yaml:"node_filter,omitempty" json:"node_filter,omitempty"` // The optional filter definition that TOSCA orchestrators or providers would use to select a type-compatible target node that can fulfill the associated abstract requirement at runtime.o
 	/* The following is the list of recognized keynames for a TOSCA requirement assignment’s relationship keyname which is used when Property assignments need to be provided to inputs of declared interfaces or their operations:*/
 	Relationship RequirementRelationship `yaml:"relationship,omitempty" json:"relationship,omitempty"`
 }
@@ -138,7 +138,7 @@ func (r *RequirementAssignment) UnmarshalYAML(unmarshal func(interface{}) error)
 	var test2 struct {
 		Capability   string                  `yaml:"capability,omitempty"`
 		Node         string                  `yaml:"node,omitempty"`
-		Nodefilter   NodeFilter              `yaml:"node_filter,omitempty"`
+		Nodefilter   *NodeFilter             `yaml:"node_filter,omitempty"`
 		Relationship RequirementRelationship `yaml:"relationship,omitempty"`
 	}
 	err = unmarshal(&test2)
@@ -1,44 +1,429 @@
 package toscalib
 
 import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Resolver defines a function spec that the Parser will use to resolve
 // remote Imports.
 type Resolver func(string) ([]byte, error)
 
-// DefaultResolver provides a basic implementation for retrieving imports that reference
-// remote locations. The file will be downloaded over HTTP(s) and the contents are returned.
+// Resolve makes a Resolver function usable as a ContextResolver, the context is
+// only checked before the function is called.
+func (r Resolver) Resolve(ctx context.Context, location string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r(location)
+}
+
+// ContextResolver retrieves the documents and the artifacts referenced by a
+// Service Template, the retrieval is abandoned when the context is done.
+type ContextResolver interface {
+	Resolve(ctx context.Context, location string) ([]byte, error)
+}
+
+// ResolverFunc adapts a function to a ContextResolver
+type ResolverFunc func(ctx context.Context, location string) ([]byte, error)
+
+// Resolve calls f(ctx, location)
+func (f ResolverFunc) Resolve(ctx context.Context, location string) ([]byte, error) {
+	return f(ctx, location)
+}
+
+// ResolverLayer wraps a ContextResolver to add a behaviour to it, see Chain
+type ResolverLayer func(next ContextResolver) ContextResolver
+
+// Chain stacks the layers on top of a resolver, the first layer is the first one
+// to handle a location.
+func Chain(r ContextResolver, layers ...ResolverLayer) ContextResolver {
+	for i := len(layers) - 1; i >= 0; i-- {
+		r = layers[i](r)
+	}
+	return r
+}
+
+// DefaultResolver is the resolver used by Parse, it reads the local files, the
+// embedded normative definitions and the CSAR files, and downloads the remote
+// documents over HTTP(s) with a timeout.
+var DefaultResolver ContextResolver = NewSchemeResolver()
+
+// defaultResolver is the DefaultResolver as a Resolver function
 func defaultResolver(location string) ([]byte, error) {
-	var r []byte
+	return DefaultResolver.Resolve(context.Background(), location)
+}
 
-	u, err := url.Parse(location)
-	if err != nil {
-		return r, err
+// RewriteRule replaces the prefix of the locations starting with Prefix, ie. to
+// download the documents of a public site from a mirror.
+type RewriteRule struct {
+	Prefix      string
+	Replacement string
+}
+
+// Rewrite returns a layer applying the first rule matching each location
+func Rewrite(rules ...RewriteRule) ResolverLayer {
+	return func(next ContextResolver) ContextResolver {
+		return ResolverFunc(func(ctx context.Context, location string) ([]byte, error) {
+			for _, rule := range rules {
+				if strings.HasPrefix(location, rule.Prefix) {
+					location = rule.Replacement + location[len(rule.Prefix):]
+					break
+				}
+			}
+			return next.Resolve(ctx, location)
+		})
 	}
+}
 
-	switch u.Scheme {
-	case "http", "https":
-		var res *http.Response
-		res, err = http.Get(u.String())
-		if err != nil {
-			return r, err
+// AllowHosts returns a layer refusing the remote locations whose host is not one
+// of the given hosts, the local locations are always allowed. The redirects
+// followed by the HTTPResolver are refused as well when they lead to another host.
+func AllowHosts(hosts ...string) ResolverLayer {
+	allowed := make(map[string]bool, len(hosts))
+	for _, h := range hosts {
+		allowed[strings.ToLower(h)] = true
+	}
+	isAllowed := func(u *url.URL) bool {
+		host := u.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
 		}
-		defer res.Body.Close()
-		r, err = ioutil.ReadAll(res.Body)
+		return allowed[strings.ToLower(u.Host)] || allowed[strings.ToLower(host)]
+	}
+	return func(next ContextResolver) ContextResolver {
+		return ResolverFunc(func(ctx context.Context, location string) ([]byte, error) {
+			u, err := url.Parse(location)
+			if err != nil || u.Host == "" {
+				return next.Resolve(ctx, location)
+			}
+			if !isAllowed(u) {
+				return nil, fmt.Errorf("Host %q of %s is not allowed", u.Host, location)
+			}
+			// the hosts must be allowed by every layer
+			check := isAllowed
+			if prev, ok := hostFilter(ctx); ok {
+				check = func(u *url.URL) bool { return prev(u) && isAllowed(u) }
+			}
+			return next.Resolve(context.WithValue(ctx, hostFilterKey{}, check), location)
+		})
+	}
+}
+
+type hostFilterKey struct{}
+
+func hostFilter(ctx context.Context) (func(*url.URL) bool, bool) {
+	allowed, ok := ctx.Value(hostFilterKey{}).(func(*url.URL) bool)
+	return allowed, ok
+}
+
+type sizeLimitKey struct{}
+
+// LimitSize returns a layer refusing the documents larger than max bytes, the
+// HTTPResolver stops the download as soon as the limit is exceeded.
+func LimitSize(max int64) ResolverLayer {
+	return func(next ContextResolver) ContextResolver {
+		return ResolverFunc(func(ctx context.Context, location string) ([]byte, error) {
+			data, err := next.Resolve(context.WithValue(ctx, sizeLimitKey{}, max), location)
+			if err == nil && int64(len(data)) > max {
+				return nil, fmt.Errorf("%s is larger than %d bytes", location, max)
+			}
+			return data, err
+		})
+	}
+}
+
+func sizeLimit(ctx context.Context) (int64, bool) {
+	max, ok := ctx.Value(sizeLimitKey{}).(int64)
+	return max, ok
+}
+
+type credentialKey struct{}
+
+// WithCredential returns a context carrying the credential to apply to the
+// requests made to retrieve a location, see CredentialFromContext.
+func WithCredential(ctx context.Context, cred Credential) context.Context {
+	return context.WithValue(ctx, credentialKey{}, cred)
+}
+
+// CredentialFromContext returns the credential of the repository a location
+// belongs to. The HTTPResolver applies it with the registered CredentialProvider,
+// the custom resolvers may apply it themselves.
+func CredentialFromContext(ctx context.Context) (Credential, bool) {
+	cred, ok := ctx.Value(credentialKey{}).(Credential)
+	return cred, ok
+}
+
+// SchemeResolver dispatches the locations to the resolver registered for their
+// URL scheme, the locations without scheme are local files.
+// A SchemeResolver is safe for concurrent use.
+type SchemeResolver struct {
+	mu      sync.RWMutex
+	schemes map[string]ContextResolver
+}
+
+// NewSchemeResolver returns a SchemeResolver handling the file, http, https,
+// csar and embedded schemes.
+func NewSchemeResolver() *SchemeResolver {
+	r := &SchemeResolver{schemes: make(map[string]ContextResolver)}
+	httpResolver := &HTTPResolver{}
+	r.Register("file", ResolverFunc(resolveFile))
+	r.Register("http", httpResolver)
+	r.Register("https", httpResolver)
+	r.Register("csar", ResolverFunc(resolveCsar))
+	r.Register("embedded", ResolverFunc(resolveEmbedded))
+	return r
+}
+
+// Register sets the resolver of the locations of a scheme
+func (r *SchemeResolver) Register(scheme string, resolver ContextResolver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.schemes[strings.ToLower(scheme)] = resolver
+}
+
+// Resolve retrieves a location with the resolver of its scheme
+func (r *SchemeResolver) Resolve(ctx context.Context, location string) ([]byte, error) {
+	scheme := "file"
+	// a single letter is the drive of a windows path
+	if u, err := url.Parse(location); err == nil && len(u.Scheme) > 1 {
+		scheme = strings.ToLower(u.Scheme)
+	}
+	r.mu.RLock()
+	resolver, ok := r.schemes[scheme]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("No resolver for the scheme %q of %s", scheme, location)
+	}
+	return resolver.Resolve(ctx, location)
+}
+
+// resolveFile reads a local file, given by its path or its file: URL
+func resolveFile(ctx context.Context, location string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(location, "file:") {
+		u, err := url.Parse(location)
 		if err != nil {
-			return r, err
+			return nil, err
+		}
+		location = filepath.FromSlash(u.Path)
+	}
+	return ioutil.ReadFile(location)
+}
+
+// resolveEmbedded reads a normative definition, ie. embedded:NormativeTypes/nodes
+func resolveEmbedded(ctx context.Context, location string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return Asset(strings.TrimPrefix(strings.TrimPrefix(location, "embedded:"), "//"))
+}
+
+// resolveCsar reads a file of a CSAR archive, the location is the path of the
+// archive and the path of the file within, ie. csar:/tmp/app.zip!/Definitions/app.yaml
+func resolveCsar(ctx context.Context, location string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	parts := strings.SplitN(strings.TrimPrefix(location, "csar:"), "!", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("Invalid CSAR location %s, expected csar:<archive>!<file>", location)
+	}
+	rc, err := zip.OpenReader(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	name := strings.TrimPrefix(parts[1], "/")
+	for _, f := range rc.File {
+		if f.Name != name {
+			continue
 		}
-		return r, nil
-	default:
-		r, err = ioutil.ReadFile(location)
+		r, err := f.Open()
 		if err != nil {
-			return r, err
+			return nil, err
 		}
-		return r, nil
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	}
+	return nil, fmt.Errorf("%s not found in %s", name, parts[0])
+}
+
+// DefaultHTTPTimeout is the timeout of the requests of the HTTPResolvers without client
+const DefaultHTTPTimeout = 30 * time.Second
+
+// HTTPResolver downloads the documents over HTTP(s). When it has a cache directory
+// the downloaded documents are kept there, and revalidated with their ETag and
+// Last-Modified date; in offline mode only the cached documents are available.
+// An HTTPResolver is safe for concurrent use.
+type HTTPResolver struct {
+	Client   *http.Client // The client making the requests, with a DefaultHTTPTimeout when nil.
+	CacheDir string       // The optional directory of the cached documents.
+	Offline  bool         // No request is made, the documents are read from the cache.
+
+	mu sync.Mutex
+}
+
+// cacheEntry describes a cached document
+type cacheEntry struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+var defaultHTTPClient = &http.Client{Timeout: DefaultHTTPTimeout}
+
+// Resolve downloads a document, applying the credential and the size limit of
+// the context
+func (r *HTTPResolver) Resolve(ctx context.Context, location string) ([]byte, error) {
+	entry, cached := r.cached(location)
+	if r.Offline {
+		if !cached {
+			return nil, fmt.Errorf("%s is not available offline", location)
+		}
+		return r.readCache(location)
+	}
+
+	req, err := http.NewRequest("GET", location, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if cred, ok := CredentialFromContext(ctx); ok {
+		if err := authorize(req, cred); err != nil {
+			return nil, err
+		}
+	}
+	if cached {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	client := r.Client
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	if allowed, ok := hostFilter(ctx); ok {
+		client = filterRedirects(client, allowed)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotModified && cached {
+		return r.readCache(location)
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("Cannot retrieve %s: %s", location, res.Status)
 	}
 
+	var body io.Reader = res.Body
+	max, limited := sizeLimit(ctx)
+	if limited {
+		body = io.LimitReader(res.Body, max+1)
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if limited && int64(len(data)) > max {
+		return nil, fmt.Errorf("%s is larger than %d bytes", location, max)
+	}
+
+	if r.CacheDir != "" {
+		entry := cacheEntry{URL: location, ETag: res.Header.Get("ETag"), LastModified: res.Header.Get("Last-Modified")}
+		if err := r.writeCache(entry, data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// filterRedirects returns a copy of the client refusing the redirects to the
+// hosts not allowed
+func filterRedirects(client *http.Client, allowed func(*url.URL) bool) *http.Client {
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if !allowed(req.URL) {
+			return fmt.Errorf("Host %q of %s is not allowed", req.URL.Host, req.URL)
+		}
+		if client.CheckRedirect != nil {
+			return client.CheckRedirect(req, via)
+		}
+		// the default policy of the http package
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &c
+}
+
+// cachePath returns the path of the cached document of a location, its entry
+// has the .json extension
+func (r *HTTPResolver) cachePath(location string) string {
+	sum := sha256.Sum256([]byte(location))
+	return filepath.Join(r.CacheDir, hex.EncodeToString(sum[:]))
+}
+
+func (r *HTTPResolver) cached(location string) (cacheEntry, bool) {
+	var entry cacheEntry
+	if r.CacheDir == "" {
+		return entry, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := ioutil.ReadFile(r.cachePath(location) + ".json")
+	if err != nil || json.Unmarshal(data, &entry) != nil || entry.URL != location {
+		return entry, false
+	}
+	if _, err := os.Stat(r.cachePath(location)); err != nil {
+		return entry, false
+	}
+	return entry, true
+}
+
+func (r *HTTPResolver) readCache(location string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return ioutil.ReadFile(r.cachePath(location))
+}
+
+func (r *HTTPResolver) writeCache(entry cacheEntry, data []byte) error {
+	meta, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := os.MkdirAll(r.CacheDir, 0755); err != nil {
+		return err
+	}
+	p := r.cachePath(entry.URL)
+	if err := ioutil.WriteFile(p, data, 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p+".json", meta, 0644)
 }
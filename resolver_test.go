@@ -0,0 +1,161 @@
+package toscalib
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestResolverFunc(t *testing.T) {
+	var r ContextResolver = Resolver(func(location string) ([]byte, error) {
+		return []byte(location), nil
+	})
+	if b, err := r.Resolve(context.Background(), "a.yaml"); err != nil || string(b) != "a.yaml" {
+		t.Errorf("unexpected result %q (%v)", b, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.Resolve(ctx, "a.yaml"); err != context.Canceled {
+		t.Errorf("expected the cancellation to be reported, got %v", err)
+	}
+
+	var s ServiceTemplateDefinition
+	err := s.ParseSourceContext(ctx, "./tests/tosca_helloworld.yaml", DefaultResolver, ParserHooks{ParsedSTD: noop})
+	if err != context.Canceled {
+		t.Errorf("expected the parsing to be cancelled, got %v", err)
+	}
+}
+
+func TestHTTPResolverCache(t *testing.T) {
+	var downloads, revalidations int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&revalidations, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(&downloads, 1)
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, "tosca_definitions_version: tosca_simple_yaml_1_0\n")
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "toscalib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := &HTTPResolver{CacheDir: dir}
+	for i := 0; i < 3; i++ {
+		b, err := r.Resolve(context.Background(), srv.URL+"/types.yaml")
+		if err != nil || !strings.HasPrefix(string(b), "tosca_definitions_version") {
+			t.Fatalf("unexpected document %q (%v)", b, err)
+		}
+	}
+	if downloads != 1 || revalidations != 2 {
+		t.Errorf("expected 1 download and 2 revalidations, got %d and %d", downloads, revalidations)
+	}
+
+	offline := &HTTPResolver{CacheDir: dir, Offline: true}
+	if _, err := offline.Resolve(context.Background(), srv.URL+"/types.yaml"); err != nil {
+		t.Error(err)
+	}
+	if _, err := offline.Resolve(context.Background(), srv.URL+"/other.yaml"); err == nil {
+		t.Error("expected an uncached document to be unavailable offline")
+	}
+	if downloads != 1 || revalidations != 2 {
+		t.Error("no request must be made offline")
+	}
+}
+
+func TestResolverLayers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "mirrored %s", r.URL.Path)
+	}))
+	defer srv.Close()
+
+	r := Chain(NewSchemeResolver(),
+		Rewrite(RewriteRule{Prefix: "https://types.example.com/", Replacement: srv.URL + "/mirror/"}),
+		AllowHosts(strings.TrimPrefix(srv.URL, "http://")),
+		LimitSize(1024),
+	)
+
+	b, err := r.Resolve(context.Background(), "https://types.example.com/mycorp.yaml")
+	if err != nil || string(b) != "mirrored /mirror/mycorp.yaml" {
+		t.Errorf("unexpected result %q (%v)", b, err)
+	}
+	if _, err := r.Resolve(context.Background(), "https://elsewhere.example.com/mycorp.yaml"); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("expected the host to be refused, got %v", err)
+	}
+	if _, err := r.Resolve(context.Background(), "./tests/tosca_helloworld.yaml"); err != nil {
+		t.Errorf("the local files must be allowed, got %v", err)
+	}
+	if _, err := r.Resolve(context.Background(), "./tests/tosca_elk.yaml"); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("expected the size limit to be reported, got %v", err)
+	}
+	small := Chain(NewSchemeResolver(), LimitSize(8))
+	if _, err := small.Resolve(context.Background(), srv.URL+"/large"); err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("expected the download to be stopped, got %v", err)
+	}
+}
+
+func TestAllowHostsRedirects(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "redirected %s", r.URL.Path)
+	}))
+	defer other.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/away.yaml":
+			http.Redirect(w, r, other.URL+r.URL.Path, http.StatusFound)
+		case "/moved.yaml":
+			http.Redirect(w, r, "/types.yaml", http.StatusMovedPermanently)
+		default:
+			fmt.Fprintf(w, "served %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	r := Chain(NewSchemeResolver(), AllowHosts(strings.TrimPrefix(srv.URL, "http://")))
+	if b, err := r.Resolve(context.Background(), srv.URL+"/moved.yaml"); err != nil || string(b) != "served /types.yaml" {
+		t.Errorf("unexpected result %q (%v)", b, err)
+	}
+	if _, err := r.Resolve(context.Background(), srv.URL+"/away.yaml"); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("expected the redirect to be refused, got %v", err)
+	}
+}
+
+func TestSchemeResolver(t *testing.T) {
+	r := NewSchemeResolver()
+	ctx := context.Background()
+
+	name := AssetNames()[0]
+	expected, _ := Asset(name)
+	if b, err := r.Resolve(ctx, "embedded:"+name); err != nil || string(b) != string(expected) {
+		t.Errorf("unexpected embedded definition (%v)", err)
+	}
+
+	if b, err := r.Resolve(ctx, "csar:./tests/csar_elk.zip!/TOSCA-Metadata/TOSCA.meta"); err != nil || !strings.Contains(string(b), "Entry-Definitions") {
+		t.Errorf("unexpected CSAR file %q (%v)", b, err)
+	}
+	if _, err := r.Resolve(ctx, "csar:./tests/csar_elk.zip!/missing.yaml"); err == nil {
+		t.Error("expected a missing CSAR file to fail")
+	}
+
+	if _, err := r.Resolve(ctx, "ftp://example.com/types.yaml"); err == nil {
+		t.Error("expected an unknown scheme to fail")
+	}
+	r.Register("ftp", ResolverFunc(func(ctx context.Context, location string) ([]byte, error) {
+		return []byte("ftp"), nil
+	}))
+	if b, err := r.Resolve(ctx, "ftp://example.com/types.yaml"); err != nil || string(b) != "ftp" {
+		t.Errorf("unexpected result %q (%v)", b, err)
+	}
+}
@@ -16,7 +16,12 @@ limitations under the License.
 
 package toscalib
 
-import "github.com/kenjones-cisco/mergo"
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kenjones-cisco/mergo"
+)
 
 // ServiceTemplateDefinition is the meta structure containing an entire tosca document as described in
 // http://docs.oasis-open.org/tosca/TOSCA-Simple-Profile-YAML/v1.0/csd03/TOSCA-Simple-Profile-YAML-v1.0-csd03.html
@@ -36,6 +41,20 @@ type ServiceTemplateDefinition struct {
 	GroupTypes         map[string]GroupType            `yaml:"group_types,omitempty" json:"group_types,omitempty"`
 	PolicyTypes        map[string]PolicyType           `yaml:"policy_types" json:"policy_types"`
 	TopologyTemplate   TopologyTemplateType            `yaml:"topology_template" json:"topology_template"` // Defines the topology template of an application or service, consisting of node templates that represent the application’s or service’s components, as well as relationship templates representing relations between the components.
+	Sources            map[string]SourcePosition       `yaml:"-" json:"-"`                                 // The position of each parsed element in its source document, indexed by YAML path.
+	OperationOutputs   map[string]OperationOutputs     `yaml:"-" json:"-"`                                 // The outputs of the operations run on each Node or Relationship Template, see SetOperationOutput.
+
+	resolver ContextResolver // retrieves the artifacts of the repositories as the imports were
+	flats    *flatTypes      // the flattened types, computed when the document is resolved
+}
+
+// MarshalYAML writes the Service Template Definition as a self-contained document,
+// the imports are left out as the imported definitions are already merged in.
+func (s ServiceTemplateDefinition) MarshalYAML() (interface{}, error) {
+	type document ServiceTemplateDefinition
+	d := document(s)
+	d.Imports = nil
+	return d, nil
 }
 
 func (s *ServiceTemplateDefinition) resolve() {
@@ -45,6 +64,17 @@ func (s *ServiceTemplateDefinition) resolve() {
 	// resolve inherited data
 	ft := flattenHierarchy(*s)
 	s.TopologyTemplate.extendFrom(ft)
+	s.flats = &ft
+}
+
+// flattened returns the flattened types of the document, the ones computed when
+// the document was resolved if any
+func (s *ServiceTemplateDefinition) flattened() flatTypes {
+	if s.flats == nil {
+		ft := flattenHierarchy(*s)
+		s.flats = &ft
+	}
+	return *s.flats
 }
 
 func (s *ServiceTemplateDefinition) reflectProperties() {
@@ -71,6 +101,7 @@ func (s *ServiceTemplateDefinition) Clone() ServiceTemplateDefinition {
 	var ns ServiceTemplateDefinition
 	tmp := clone(*s)
 	ns, _ = tmp.(ServiceTemplateDefinition)
+	ns.resolver, ns.flats = s.resolver, s.flats
 	return ns
 }
 
@@ -78,6 +109,8 @@ func (s *ServiceTemplateDefinition) Clone() ServiceTemplateDefinition {
 func (s *ServiceTemplateDefinition) Merge(u ServiceTemplateDefinition) ServiceTemplateDefinition {
 	std := s.Clone()
 	_ = mergo.MergeWithOverwrite(&std, u)
+	// the types of the merged document are flattened again
+	std.flats = nil
 	return std
 }
 
@@ -137,19 +170,79 @@ func (s *ServiceTemplateDefinition) GetAttribute(node, attr string) *AttributeAs
 
 // GetInputValue retrieves an input value from Service Template Definition in
 // the raw form (function evaluation not performed), or actual value after all
-// function evaluation has completed.
+// function evaluation has completed. The actual value falls back on the default
+// of the input and is converted to its declared type.
 func (s *ServiceTemplateDefinition) GetInputValue(prop string, raw bool) interface{} {
 	if raw {
 		return s.TopologyTemplate.Inputs[prop].Value
 	}
-	input := s.TopologyTemplate.Inputs[prop].Value
-	return input.Evaluate(s, "")
+	def, ok := s.TopologyTemplate.Inputs[prop]
+	if !ok {
+		return nil
+	}
+	input := def.Value
+	if input.isEmpty() && def.Default != nil {
+		input = *newPAValue(def.Default)
+	}
+	v := input.Evaluate(s, "")
+	if out, errs := newTypeChecker(s).convert(prop, def, v); len(errs) == 0 {
+		return out
+	}
+	return v
+}
+
+// SetInputValue sets an input value on a Service Template Definition.
+// The value is converted to the type declared by the input definition (ie.
+// "8080" for an integer) and checked against its constraints, the value is
+// not set and the mismatches are returned as ValidationErrors otherwise.
+func (s *ServiceTemplateDefinition) SetInputValue(prop string, value interface{}) error {
+	path := fmt.Sprintf("topology_template.inputs.%s", prop)
+	def := s.TopologyTemplate.Inputs[prop]
+
+	out, errs := newTypeChecker(s).convert(path, def, value)
+	if len(errs) != 0 {
+		verrs := make(ValidationErrors, len(errs))
+		for i, e := range errs {
+			verrs[i] = s.newValidationError(SeverityError, e.Path, e.Message)
+		}
+		return verrs
+	}
+
+	if s.TopologyTemplate.Inputs == nil {
+		s.TopologyTemplate.Inputs = make(map[string]PropertyDefinition)
+	}
+	def.Value = *newPAValue(out)
+	s.TopologyTemplate.Inputs[prop] = def
+	return nil
 }
 
-// SetInputValue sets an input value on a Service Template Definition
-func (s *ServiceTemplateDefinition) SetInputValue(prop string, value interface{}) {
-	v := newPAValue(value)
-	s.TopologyTemplate.Inputs[prop] = PropertyDefinition{Value: *v}
+// SetInputs sets the deployment values of the inputs. All the problems are
+// reported at once: values not matching their definition, undeclared inputs
+// and required inputs (without default) left without a value.
+func (s *ServiceTemplateDefinition) SetInputs(inputs map[string]interface{}) error {
+	var verrs ValidationErrors
+	for _, name := range sortedKeys(inputs) {
+		if _, ok := s.TopologyTemplate.Inputs[name]; !ok {
+			verrs = append(verrs, s.newValidationError(SeverityError, fmt.Sprintf("topology_template.inputs.%s", name), fmt.Sprintf("input %q is not declared", name)))
+			continue
+		}
+		if err := s.SetInputValue(name, inputs[name]); err != nil {
+			verrs = append(verrs, err.(ValidationErrors)...)
+		}
+	}
+
+	for _, name := range sortedKeys(s.TopologyTemplate.Inputs) {
+		def := s.TopologyTemplate.Inputs[name]
+		if def.Required && def.Value.isEmpty() && def.Default == nil {
+			verrs = append(verrs, s.newValidationError(SeverityError, fmt.Sprintf("topology_template.inputs.%s", name), fmt.Sprintf("required input %q has no value", name)))
+		}
+	}
+
+	if len(verrs) == 0 {
+		return nil
+	}
+	sort.Stable(byPath(verrs))
+	return verrs
 }
 
 // SetAttribute provides the ability to set a value to a named attribute
@@ -160,6 +253,21 @@ func (s *ServiceTemplateDefinition) SetAttribute(node, attr string, value interf
 	}
 }
 
+// GetNodesOfType returns the names, in alphabetical order, of the Node Templates
+// whose type is the named Node Type or is derived from it.
+func (s *ServiceTemplateDefinition) GetNodesOfType(typeName string) []string {
+	var names []string
+	for _, name := range sortedKeys(s.TopologyTemplate.NodeTemplates) {
+		for _, t := range s.nodeTypeHierarchy(s.TopologyTemplate.NodeTemplates[name].Type) {
+			if t == typeName {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	return names
+}
+
 func (s *ServiceTemplateDefinition) nodeTypeHierarchy(name string) []string {
 	var types []string
 	typeName := name
@@ -180,11 +288,14 @@ func (s *ServiceTemplateDefinition) findHostNode(name string) *NodeTemplate {
 		return nil
 	}
 
-	if req := nt.getRequirementByRelationship("tosca.relationships.HostedOn"); req != nil {
-		// TODO(kenjones): assume the requirement has a node specified, otherwise need to use the
-		// value stored on the node type to get a list of node templates and then filter
-		// based on the requirement node filter sequence.
-		if targetNode := s.GetNodeTemplate(req.Node); targetNode != nil {
+	if idx, rname, ok := nt.requirementByRelationship("tosca.relationships.HostedOn"); ok {
+		req := nt.Requirements[idx][rname]
+		targetNode := s.GetNodeTemplate(req.Node)
+		if targetNode == nil {
+			// the requirement is abstract, select the node template fulfilling it
+			targetNode = s.GetNodeTemplate(s.matchRequirement(name, idx, rname))
+		}
+		if targetNode != nil {
 			nth := s.nodeTypeHierarchy(nt.Type)
 			if targetNode.checkCapabilityMatch(req.Capability, nth) {
 				return targetNode
@@ -16,14 +16,17 @@ limitations under the License.
 package toscalib
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
+	"gopkg.in/yaml.v2"
 )
 
 func TestFlattenNodeType(t *testing.T) {
@@ -40,6 +43,13 @@ func TestFlattenNodeType(t *testing.T) {
 	}
 }
 
+// workingDirImports are the documents importing a document whose own imports are
+// relative to the working directory, they are located from the importing document
+// instead and not found
+var workingDirImports = map[string]bool{
+	"test_template_with_nested_imports.yaml": true,
+}
+
 func TestParse(t *testing.T) {
 	files, _ := ioutil.ReadDir("./tests")
 	for _, f := range files {
@@ -52,6 +62,12 @@ func TestParse(t *testing.T) {
 					t.Fatal(err)
 				}
 				err = s.Parse(o)
+				if workingDirImports[f.Name()] {
+					if err == nil {
+						t.Error(fname, "imports files relative to the working directory but it did not error out")
+					}
+					continue
+				}
 				if err != nil {
 					t.Log("Error in processing", fname)
 					t.Fatal(err)
@@ -461,7 +477,7 @@ func TestClone(t *testing.T) {
 	for _, f := range files {
 		if !f.IsDir() {
 			fname := fmt.Sprintf("./tests/%v", f.Name())
-			if filepath.Ext(fname) == ".yaml" {
+			if filepath.Ext(fname) == ".yaml" && !workingDirImports[f.Name()] {
 				var s ServiceTemplateDefinition
 				o, err := os.Open(fname)
 				if err != nil {
@@ -606,3 +622,201 @@ func TestMerge(t *testing.T) {
 	}
 
 }
+
+func TestSetInputValue(t *testing.T) {
+	fname := "./tests/tosca_typed_inputs.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	if err = s.SetInputValue("port", "8080"); err != nil {
+		t.Fatal(err)
+	}
+	if v := s.GetInputValue("port", false); v != 8080 {
+		t.Errorf("expected port to be converted to integer 8080, actual %v (%T)", v, v)
+	}
+	if s.TopologyTemplate.Inputs["port"].Type != "integer" {
+		t.Error("setting the value of port lost its definition")
+	}
+
+	if err = s.SetInputValue("mem_size", "2 GB"); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := s.GetInputValue("mem_size", false).(Scalar); !ok || v.BaseValue() != 2000000000 {
+		t.Errorf("expected mem_size to be converted to a scalar-unit.size, actual %v", v)
+	}
+
+	if v := s.GetInputValue("cpus", false); v != 2 {
+		t.Errorf("expected cpus to default to 2, actual %v (%T)", v, v)
+	}
+
+	if err = s.SetInputValue("port", "70000"); err == nil {
+		t.Error("expected port 70000 to violate its constraints")
+	}
+	if err = s.SetInputValue("port", "http"); err == nil {
+		t.Error("expected port http to be rejected")
+	}
+	if v := s.GetInputValue("port", false); v != 8080 {
+		t.Errorf("expected port to keep its valid value 8080, actual %v", v)
+	}
+}
+
+func TestParseWithInputs(t *testing.T) {
+	fname := "./tests/tosca_typed_inputs.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.ParseWithInputs(o, map[string]interface{}{"debug": "perhaps", "unknown": 1})
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected ValidationErrors, actual %v", err)
+	}
+
+	want := []string{
+		"topology_template.inputs.admin",
+		"topology_template.inputs.debug",
+		"topology_template.inputs.mem_size",
+		"topology_template.inputs.port",
+		"topology_template.inputs.unknown",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, actual %d:\n%v", len(want), len(errs), errs)
+	}
+	for i, path := range want {
+		if errs[i].Path != path {
+			t.Errorf("expected error on %s, actual %v", path, errs[i])
+		}
+	}
+
+	o, err = os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = ServiceTemplateDefinition{}
+	err = s.ParseWithInputs(o, map[string]interface{}{"port": 80, "mem_size": "4 GB", "admin": "root"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := s.Validate(); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+}
+
+// marshalSkipped are the documents TestMarshalRoundTrip can't parse
+var marshalSkipped = map[string]string{
+	"tosca_single_instance_wordpress_with_url_import.yaml": "imports a document over the network",
+	"test_template_with_nested_imports.yaml":               "imports files relative to the working directory",
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	files, _ := ioutil.ReadDir("./tests")
+	for _, f := range files {
+		fname := fmt.Sprintf("./tests/%v", f.Name())
+		if f.IsDir() || filepath.Ext(fname) != ".yaml" {
+			continue
+		}
+		if reason, ok := marshalSkipped[f.Name()]; ok {
+			t.Logf("skipping %s: %s", fname, reason)
+			continue
+		}
+		var s ServiceTemplateDefinition
+		o, err := os.Open(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Parse(o); err != nil {
+			t.Fatal(fname, err)
+		}
+
+		b, err := yaml.Marshal(s)
+		if err != nil {
+			t.Fatal(fname, err)
+		}
+		var r ServiceTemplateDefinition
+		if err := r.Parse(bytes.NewReader(b)); err != nil {
+			t.Log(string(b))
+			t.Fatal(fname, err)
+		}
+
+		// the positions and the imports are not written, and the values of the
+		// templates are read back as strings
+		s.Sources, r.Sources = nil, nil
+		s.Imports = nil
+		normalizeScalars(reflect.ValueOf(&s).Elem())
+		normalizeScalars(reflect.ValueOf(&r).Elem())
+		if !reflect.DeepEqual(s, r) {
+			t.Errorf("%s does not round-trip:\n%s", fname, b)
+		}
+	}
+}
+
+// normalizeScalars replaces the scalar values of the assignments by their string
+// form, the only form a template gives them
+func normalizeScalars(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			normalizeScalars(v.Elem())
+		}
+	case reflect.Struct:
+		if a, ok := v.Addr().Interface().(*Assignment); ok {
+			switch a.Value.(type) {
+			case bool, int, int64, uint64, float64:
+				a.Value = fmt.Sprint(a.Value)
+			}
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).CanSet() {
+				normalizeScalars(v.Field(i))
+			}
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(v.MapIndex(k))
+			normalizeScalars(e)
+			v.SetMapIndex(k, e)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			normalizeScalars(v.Index(i))
+		}
+	}
+}
+
+func TestMarshalFunctions(t *testing.T) {
+	var s ServiceTemplateDefinition
+	o, err := os.Open("./tests/tosca_single_instance_wordpress.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Parse(o); err != nil {
+		t.Fatal(err)
+	}
+	b, err := yaml.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(b)
+	for _, expected := range []string{"get_input: cpus", "get_attribute:\n        - server\n        - private_address\n", "tosca_definitions_version: tosca_simple_yaml_1_0"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in the document", expected)
+		}
+	}
+	for _, unexpected := range []string{"imports:", "refs:", "function:", "name: server"} {
+		if strings.Contains(out, unexpected) {
+			t.Errorf("unexpected %q in the document", unexpected)
+		}
+	}
+}
@@ -0,0 +1,496 @@
+package toscalib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Snapshot is the saved state of a deployment: the documents of its Service
+// Template, the values of its inputs and attributes, the outputs of the operations
+// run and the state of its instances.
+type Snapshot struct {
+	Deployment       string                            `json:"deployment"`
+	Version          int                               `json:"version"` // Set by the StateStore, starting from 1.
+	Created          time.Time                         `json:"created"`
+	Documents        map[string][]byte                 `json:"documents"`                   // The Service Template ("") and its imports, by location.
+	Inputs           map[string]interface{}            `json:"inputs,omitempty"`            // The values set on the inputs.
+	Attributes       map[string]map[string]interface{} `json:"attributes,omitempty"`        // The runtime attribute values of the Node Templates.
+	OperationOutputs map[string]OperationOutputs       `json:"operation_outputs,omitempty"` // The outputs of the operations run.
+	Instances        []NodeInstance                    `json:"instances,omitempty"`
+}
+
+// StateStore saves versioned snapshots of deployments
+type StateStore interface {
+	// Save stores a new version of the deployment and returns its version number.
+	Save(snap *Snapshot) (int, error)
+	// Load returns a version of the deployment, the latest one when version is 0.
+	Load(deployment string, version int) (*Snapshot, error)
+	// Versions returns the version numbers saved for the deployment, in order.
+	Versions(deployment string) ([]int, error)
+}
+
+// Deployment is a Service Template deployed as a set of node instances
+type Deployment struct {
+	Name      string
+	Template  *ServiceTemplateDefinition
+	Instances *InstanceModel
+
+	documents map[string][]byte
+	defaults  map[string]map[string]interface{} // The attribute values of the parsed template.
+}
+
+// NewDeployment parses the Service Template and instantiates its topology (see
+// Instantiate). The template and every document it imports are recorded so that
+// the deployment can be restored from its snapshots without fetching them again.
+// The imports are retrieved using the resolver, or from the file system or over
+// HTTP(s) when it is nil.
+func NewDeployment(name string, r io.Reader, resolver Resolver) (*Deployment, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	base := DefaultResolver
+	if resolver != nil {
+		base = resolver
+	}
+
+	d := &Deployment{Name: name, Template: &ServiceTemplateDefinition{}, documents: map[string][]byte{"": data}}
+	var mu sync.Mutex
+	recorder := ResolverFunc(func(ctx context.Context, location string) ([]byte, error) {
+		b, err := base.Resolve(ctx, location)
+		if err == nil {
+			mu.Lock()
+			d.documents[location] = b
+			mu.Unlock()
+		}
+		return b, err
+	})
+	if err := d.Template.ParseReaderContext(context.Background(), bytes.NewReader(data), recorder, ParserHooks{ParsedSTD: noop}); err != nil {
+		return nil, err
+	}
+	d.defaults = attributeValues(d.Template)
+	if d.Instances, err = d.Template.Instantiate(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// RestoreDeployment rebuilds a deployment from one of its snapshots
+func RestoreDeployment(snap *Snapshot) (*Deployment, error) {
+	data, ok := snap.Documents[""]
+	if !ok {
+		return nil, fmt.Errorf("Snapshot %d of deployment %q has no service template", snap.Version, snap.Deployment)
+	}
+	replay := func(location string) ([]byte, error) {
+		if b, ok := snap.Documents[location]; ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("Document %q is not part of the snapshot", location)
+	}
+
+	s := &ServiceTemplateDefinition{}
+	if err := s.ParseReader(bytes.NewReader(data), replay, ParserHooks{ParsedSTD: noop}); err != nil {
+		return nil, err
+	}
+	defaults := attributeValues(s)
+	for _, name := range sortedKeys(snap.Inputs) {
+		if err := s.SetInputValue(name, snap.Inputs[name]); err != nil {
+			return nil, err
+		}
+	}
+	for _, node := range sortedKeys(snap.Attributes) {
+		for attr, v := range snap.Attributes[node] {
+			s.SetAttribute(node, attr, v)
+		}
+	}
+	for entity, outputs := range snap.OperationOutputs {
+		for intf, ops := range outputs {
+			for op, values := range ops {
+				s.SetOperationOutputs(entity, intf, op, values)
+			}
+		}
+	}
+
+	return &Deployment{
+		Name:      snap.Deployment,
+		Template:  s,
+		Instances: s.RestoreInstances(snap.Instances),
+		documents: copyDocuments(snap.Documents),
+		defaults:  defaults,
+	}, nil
+}
+
+// attributeValues returns the values of the attributes of the Node Templates,
+// the properties they reflect included
+func attributeValues(s *ServiceTemplateDefinition) map[string]map[string]interface{} {
+	values := make(map[string]map[string]interface{})
+	for node, nt := range s.TopologyTemplate.NodeTemplates {
+		for name, attr := range nt.Attributes {
+			if attr.Function != "" || attr.Value == nil {
+				continue
+			}
+			if values[node] == nil {
+				values[node] = make(map[string]interface{})
+			}
+			values[node][name] = attr.Value
+		}
+	}
+	return values
+}
+
+func copyDocuments(docs map[string][]byte) map[string][]byte {
+	cp := make(map[string][]byte, len(docs))
+	for location, data := range docs {
+		cp[location] = data
+	}
+	return cp
+}
+
+// Snapshot captures the current state of the deployment, the attributes whose
+// value differs from the template and the state of the Node Templates.
+func (d *Deployment) Snapshot() *Snapshot {
+	s := d.Template
+	snap := &Snapshot{
+		Deployment:       d.Name,
+		Created:          time.Now().UTC(),
+		Documents:        copyDocuments(d.documents),
+		Inputs:           make(map[string]interface{}),
+		Attributes:       make(map[string]map[string]interface{}),
+		OperationOutputs: make(map[string]OperationOutputs),
+		Instances:        d.Instances.Instances(""),
+	}
+	for name, input := range s.TopologyTemplate.Inputs {
+		if input.Value.Function == "" && input.Value.Value != nil {
+			snap.Inputs[name] = input.Value.Value
+		}
+	}
+	// only the runtime values are saved, the template gives the others back
+	for node, values := range attributeValues(s) {
+		for name, v := range values {
+			if def, ok := d.defaults[node][name]; ok && name != "state" && reflect.DeepEqual(toJSONValue(def), toJSONValue(v)) {
+				continue
+			}
+			if snap.Attributes[node] == nil {
+				snap.Attributes[node] = make(map[string]interface{})
+			}
+			snap.Attributes[node][name] = v
+		}
+	}
+	for entity, outputs := range s.OperationOutputs {
+		snap.OperationOutputs[entity] = outputs
+	}
+	return snap
+}
+
+// Save stores a snapshot of the deployment and returns its version
+func (d *Deployment) Save(store StateStore) (int, error) {
+	return store.Save(d.Snapshot())
+}
+
+// LoadDeployment restores a version of a deployment, the latest when version is 0
+func LoadDeployment(store StateStore, name string, version int) (*Deployment, error) {
+	snap, err := store.Load(name, version)
+	if err != nil {
+		return nil, err
+	}
+	return RestoreDeployment(snap)
+}
+
+// FileStore is a StateStore keeping the snapshots of all the deployments in a
+// single JSON file, rewritten atomically on each save.
+// A FileStore is safe for concurrent use within a process.
+type FileStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+type fileStoreContent struct {
+	Deployments map[string][]*Snapshot `json:"deployments"`
+}
+
+func (f *FileStore) read() (*fileStoreContent, error) {
+	content := &fileStoreContent{Deployments: make(map[string][]*Snapshot)}
+	data, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return content, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(content); err != nil {
+		return nil, fmt.Errorf("Invalid state file %s: %v", f.Path, err)
+	}
+	for _, snaps := range content.Deployments {
+		for _, snap := range snaps {
+			snap.fromJSON()
+		}
+	}
+	return content, nil
+}
+
+func (f *FileStore) write(content *fileStoreContent) error {
+	data, err := json.MarshalIndent(content, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(f.Path), filepath.Base(f.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
+
+// Save stores the snapshot as the next version of its deployment
+func (f *FileStore) Save(snap *Snapshot) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	content, err := f.read()
+	if err != nil {
+		return 0, err
+	}
+	cp := *snap
+	cp.toJSON()
+	snaps := content.Deployments[snap.Deployment]
+	cp.Version = len(snaps) + 1
+	content.Deployments[snap.Deployment] = append(snaps, &cp)
+	if err := f.write(content); err != nil {
+		return 0, err
+	}
+	snap.Version = cp.Version
+	return cp.Version, nil
+}
+
+// Load returns a version of the deployment, the latest one when version is 0
+func (f *FileStore) Load(deployment string, version int) (*Snapshot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	content, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	snaps := content.Deployments[deployment]
+	if len(snaps) == 0 {
+		return nil, fmt.Errorf("Deployment %q not found", deployment)
+	}
+	if version == 0 {
+		version = len(snaps)
+	}
+	if version < 1 || version > len(snaps) {
+		return nil, fmt.Errorf("Version %d of deployment %q not found", version, deployment)
+	}
+	return snaps[version-1], nil
+}
+
+// Versions returns the version numbers saved for the deployment
+func (f *FileStore) Versions(deployment string) ([]int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	content, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	var versions []int
+	for _, snap := range content.Deployments[deployment] {
+		versions = append(versions, snap.Version)
+	}
+	return versions, nil
+}
+
+// toJSON converts the values of the snapshot to types supported by encoding/json
+func (s *Snapshot) toJSON() {
+	s.Inputs = toJSONValue(s.Inputs).(map[string]interface{})
+	attrs := make(map[string]map[string]interface{}, len(s.Attributes))
+	for node, values := range s.Attributes {
+		attrs[node] = toJSONValue(values).(map[string]interface{})
+	}
+	s.Attributes = attrs
+	outputs := make(map[string]OperationOutputs, len(s.OperationOutputs))
+	for entity, oo := range s.OperationOutputs {
+		outputs[entity] = make(OperationOutputs)
+		for intf, ops := range oo {
+			outputs[entity][intf] = make(map[string]map[string]interface{})
+			for op, values := range ops {
+				outputs[entity][intf][op] = toJSONValue(values).(map[string]interface{})
+			}
+		}
+	}
+	s.OperationOutputs = outputs
+	instances := make([]NodeInstance, len(s.Instances))
+	for i, inst := range s.Instances {
+		if inst.Attributes != nil {
+			inst.Attributes = toJSONValue(inst.Attributes).(map[string]interface{})
+		}
+		instances[i] = inst
+	}
+	s.Instances = instances
+}
+
+// fromJSON converts the numbers decoded from JSON to int or float64
+func (s *Snapshot) fromJSON() {
+	for k, v := range s.Inputs {
+		s.Inputs[k] = fromJSONValue(v)
+	}
+	for _, values := range s.Attributes {
+		for k, v := range values {
+			values[k] = fromJSONValue(v)
+		}
+	}
+	for _, oo := range s.OperationOutputs {
+		for _, ops := range oo {
+			for _, values := range ops {
+				for k, v := range values {
+					values[k] = fromJSONValue(v)
+				}
+			}
+		}
+	}
+	for _, inst := range s.Instances {
+		for k, v := range inst.Attributes {
+			inst.Attributes[k] = fromJSONValue(v)
+		}
+	}
+}
+
+// toJSONValue converts the maps decoded from YAML, whose keys are not strings,
+// and the TOSCA values (ie. Version, Scalar) to plain JSON values.
+func toJSONValue(v interface{}) interface{} {
+	switch v.(type) {
+	case nil, string, bool, json.Number:
+		return v
+	case Version, Scalar, time.Time:
+		return fmt.Sprintf("%v", v)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		m := make(map[string]interface{}, rv.Len())
+		for _, k := range rv.MapKeys() {
+			m[fmt.Sprintf("%v", k.Interface())] = toJSONValue(rv.MapIndex(k).Interface())
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		l := make([]interface{}, rv.Len())
+		for i := range l {
+			l[i] = toJSONValue(rv.Index(i).Interface())
+		}
+		return l
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil
+		}
+		return toJSONValue(rv.Elem().Interface())
+	}
+	return v
+}
+
+func fromJSONValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return int(i)
+		}
+		f, _ := t.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = fromJSONValue(e)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = fromJSONValue(e)
+		}
+	}
+	return v
+}
+
+// SnapshotChange is a value that differs between two snapshots
+type SnapshotChange struct {
+	Path string      // The path of the value (ie. instances.server_0.state).
+	Old  interface{} // The value in the first snapshot, nil when it was added.
+	New  interface{} // The value in the second snapshot, nil when it was removed.
+}
+
+// DiffSnapshots returns the inputs, attributes, operation outputs and instance
+// states and attributes that differ between two snapshots, ordered by path.
+func DiffSnapshots(from, to *Snapshot) []SnapshotChange {
+	a, b := from.values(), to.values()
+	var changes []SnapshotChange
+	for _, path := range sortedKeys(a) {
+		if nv, ok := b[path]; !ok || !reflect.DeepEqual(toJSONValue(a[path]), toJSONValue(nv)) {
+			changes = append(changes, SnapshotChange{Path: path, Old: a[path], New: nv})
+		}
+	}
+	for _, path := range sortedKeys(b) {
+		if _, ok := a[path]; !ok {
+			changes = append(changes, SnapshotChange{Path: path, New: b[path]})
+		}
+	}
+	sort.Stable(byChangePath(changes))
+	return changes
+}
+
+type byChangePath []SnapshotChange
+
+func (b byChangePath) Len() int           { return len(b) }
+func (b byChangePath) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byChangePath) Less(i, j int) bool { return b[i].Path < b[j].Path }
+
+// values flattens the runtime values of the snapshot by path
+func (s *Snapshot) values() map[string]interface{} {
+	values := make(map[string]interface{})
+	path := func(parts ...string) string {
+		return strings.Join(parts, ".")
+	}
+	for k, v := range s.Inputs {
+		values[path("inputs", k)] = v
+	}
+	for node, attrs := range s.Attributes {
+		for k, v := range attrs {
+			values[path("attributes", node, k)] = v
+		}
+	}
+	for entity, oo := range s.OperationOutputs {
+		for intf, ops := range oo {
+			for op, outputs := range ops {
+				for k, v := range outputs {
+					values[path("operation_outputs", entity, intf, op, k)] = v
+				}
+			}
+		}
+	}
+	for _, inst := range s.Instances {
+		values[path("instances", inst.ID, "state")] = StateName(inst.State)
+		for k, v := range inst.Attributes {
+			values[path("instances", inst.ID, "attributes", k)] = v
+		}
+	}
+	return values
+}
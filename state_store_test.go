@@ -0,0 +1,147 @@
+package toscalib
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	fname := "./tests/tosca_single_instance_wordpress.yaml"
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDeployment("blog", o, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.documents["tests/custom_types/wordpress.yaml"]; !ok {
+		t.Errorf("the imported document was not recorded: %v", sortedKeys(d.documents))
+	}
+
+	dir, err := ioutil.TempDir("", "toscalib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := &FileStore{Path: filepath.Join(dir, "state.json")}
+
+	if err := d.Template.SetInputValue("db_root_pwd", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := d.Save(store); err != nil || v != 1 {
+		t.Fatalf("expected version 1, got %d (%v)", v, err)
+	}
+
+	e := &Executor{Dispatcher: &fakeDispatcher{}, Instances: d.Instances}
+	if _, err := e.Run(context.Background(), d.Template, DeployWorkflow); err != nil {
+		t.Fatal(err)
+	}
+	d.Instances.SetAttribute("server_0", "private_address", "10.0.0.1")
+	d.Template.SetAttribute("server", "public_address", "192.168.0.1")
+	d.Template.SetAttribute("server", "networks", map[string]interface{}{"private": map[string]interface{}{"addresses": []string{"10.0.0.1"}}})
+	if v, err := d.Save(store); err != nil || v != 2 {
+		t.Fatalf("expected version 2, got %d (%v)", v, err)
+	}
+
+	if versions, _ := store.Versions("blog"); !reflect.DeepEqual(versions, []int{1, 2}) {
+		t.Errorf("unexpected versions %v", versions)
+	}
+
+	// a new store on the same file resumes the deployment
+	restored, err := LoadDeployment(&FileStore{Path: store.Path}, "blog", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := restored.Template.GetInputValue("db_root_pwd", false); v != "secret" {
+		t.Errorf("input not restored, got %v", v)
+	}
+	if v := restored.Template.GetInputValue("cpus", false); v != 1 {
+		t.Errorf("input default not restored, got %v (%T)", v, v)
+	}
+	if inst, ok := restored.Instances.Instance("wordpress_0"); !ok || inst.State != StateStarted {
+		t.Errorf("instance state not restored, got %v", inst)
+	}
+	if v, _ := restored.Instances.GetAttribute("server_0", "private_address"); v != "10.0.0.1" {
+		t.Errorf("instance attribute not restored, got %v", v)
+	}
+	if v := restored.Template.GetAttribute("server", "public_address").Evaluate(restored.Template, "server"); v != "192.168.0.1" {
+		t.Errorf("attribute not restored, got %v", v)
+	}
+	networks := Assignment{Function: GetAttrFunc, Args: []interface{}{"server", "networks", "private", "addresses", 0}}
+	if v, err := networks.EvaluateE(restored.Template, ""); err != nil || v != "10.0.0.1" {
+		t.Errorf("nested attribute not restored, got %v (%v)", v, err)
+	}
+	if v, ok := restored.Template.GetOperationOutput("mysql_dbms", "Standard", "create", "implementation"); !ok || v != "mysql/mysql_dbms_install.sh" {
+		t.Errorf("operation output not restored, got %v", v)
+	}
+
+	first, err := store.Load("blog", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RestoreDeployment(first); err != nil {
+		t.Fatal(err)
+	}
+	snap := d.Snapshot()
+	snap.Documents["extra.yaml"] = nil
+	if _, ok := d.documents["extra.yaml"]; ok {
+		t.Error("the snapshot shares the documents of the deployment")
+	}
+	second, _ := store.Load("blog", 2)
+	changes := DiffSnapshots(first, second)
+	found := make(map[string]SnapshotChange)
+	for _, c := range changes {
+		found[c.Path] = c
+	}
+	if c, ok := found["instances.wordpress_0.state"]; !ok || c.Old != "initial" || c.New != "started" {
+		t.Errorf("unexpected state change %v", c)
+	}
+	if c, ok := found["instances.server_0.attributes.private_address"]; !ok || c.Old != nil || c.New != "10.0.0.1" {
+		t.Errorf("unexpected attribute change %v", c)
+	}
+	if _, ok := found["inputs.db_root_pwd"]; ok {
+		t.Error("unchanged input reported")
+	}
+
+	if _, err := store.Load("blog", 3); err == nil {
+		t.Error("expected an unknown version to fail")
+	}
+	if _, err := store.Load("unknown", 0); err == nil {
+		t.Error("expected an unknown deployment to fail")
+	}
+}
+
+func TestSnapshotRuntimeAttributes(t *testing.T) {
+	doc := `tosca_definitions_version: tosca_simple_yaml_1_0
+topology_template:
+  node_templates:
+    db:
+      type: tosca.nodes.Database
+      properties:
+        name: wordpress
+        port: 3306
+`
+	d, err := NewDeployment("db", strings.NewReader(doc), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := d.Template.GetAttribute("db", "name"); v == nil || v.Value != "wordpress" {
+		t.Fatalf("the property is not reflected as an attribute, got %v", v)
+	}
+	if snap := d.Snapshot(); len(snap.Attributes) != 0 {
+		t.Errorf("the attributes of the template must not be saved, got %v", snap.Attributes)
+	}
+
+	d.Template.SetAttribute("db", "port", 3307)
+	d.Template.SetAttribute("db", "state", "created")
+	expected := map[string]map[string]interface{}{"db": {"port": 3307, "state": "created"}}
+	if snap := d.Snapshot(); !reflect.DeepEqual(snap.Attributes, expected) {
+		t.Errorf("expected the attributes %v, got %v", expected, snap.Attributes)
+	}
+}
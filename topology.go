@@ -27,10 +27,10 @@ package toscalib
 type TopologyTemplateType struct {
 	Description           string                          `yaml:"description,omitempty" json:"description,omitempty"`
 	Inputs                map[string]PropertyDefinition   `yaml:"inputs,omitempty" json:"inputs,omitempty"`
-	NodeTemplates         map[string]NodeTemplate         `yaml:"node_templates" json:"node_templates"`
+	NodeTemplates         map[string]NodeTemplate         `yaml:"node_templates,omitempty" json:"node_templates"`
 	RelationshipTemplates map[string]RelationshipTemplate `yaml:"relationship_templates,omitempty" json:"relationship_templates,omitempty"`
-	Groups                map[string]GroupDefinition      `yaml:"groups" json:"groups"`
-	Policies              []map[string]PolicyDefinition   `yaml:"policies" json:"policies"`
+	Groups                map[string]GroupDefinition      `yaml:"groups,omitempty" json:"groups"`
+	Policies              []map[string]PolicyDefinition   `yaml:"policies,omitempty" json:"policies"`
 	Workflows             map[string]WorkflowDefinition   `yaml:"workflows,omitempty" json:"workflows,omitempty"`
 	Outputs               map[string]PropertyDefinition   `yaml:"outputs,omitempty" json:"outputs,omitempty"`
 }
@@ -87,6 +87,15 @@ func parseToscaVersion(s string) (semver.Version, error) {
 
 	// Split into major.minor.patch.pr(-meta)
 	parts := strings.SplitN(s, ".", 4)
+
+	// TOSCA versions are made of integers (ie. 14.04) which semver
+	// refuses when they have leading zeroes.
+	for i := 0; i < len(parts) && i < 3; i++ {
+		if n, err := strconv.ParseUint(parts[i], 10, 64); err == nil {
+			parts[i] = strconv.FormatUint(n, 10)
+		}
+	}
+	s = strings.Join(parts, ".")
 	if len(parts) < 3 {
 		parts = append(parts, "0")
 		s = strings.Join(parts, ".")
@@ -141,6 +150,11 @@ func (v *Version) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return fmt.Errorf("Invalid version %v: %s", s, err)
 }
 
+// MarshalYAML is used to convert Version to string
+func (v Version) MarshalYAML() (interface{}, error) {
+	return v.Version.String(), nil
+}
+
 // UNBOUNDED A.2.3 TOCSA range type
 const UNBOUNDED uint64 = 9223372036854775807
 
@@ -177,32 +191,97 @@ type Scalar struct {
 	Unit  string
 }
 
-// UnmarshalYAML implements the yaml.Unmarshaler interface
-// Unmarshals a string of the form "scalar unit" into a Scalar, validating that scalar and unit are valid
-func (s *Scalar) UnmarshalYAML(unmarshal func(interface{}) error) error {
-	var sString string
-	err := unmarshal(&sString)
-	if err != nil {
-		return err
-	}
+// scalarUnits maps each recognized unit to its class and the factor used to
+// convert a value expressed in that unit into the base unit of the class
+// (bytes, seconds or hertz).
+var scalarUnits = map[string]struct {
+	Class  string
+	Factor float64
+}{
+	"B":   {"scalar-unit.size", 1},
+	"kB":  {"scalar-unit.size", 1000},
+	"KiB": {"scalar-unit.size", 1024},
+	"MB":  {"scalar-unit.size", 1000000},
+	"MiB": {"scalar-unit.size", 1048576},
+	"GB":  {"scalar-unit.size", 1000000000},
+	"GiB": {"scalar-unit.size", 1073741824},
+	"TB":  {"scalar-unit.size", 1000000000000},
+	"TiB": {"scalar-unit.size", 1099511627776},
+	"d":   {"scalar-unit.time", 86400},
+	"h":   {"scalar-unit.time", 3600},
+	"m":   {"scalar-unit.time", 60},
+	"s":   {"scalar-unit.time", 1},
+	"ms":  {"scalar-unit.time", 0.001},
+	"us":  {"scalar-unit.time", 0.000001},
+	"ns":  {"scalar-unit.time", 0.000000001},
+	"Hz":  {"scalar-unit.frequency", 1},
+	"kHz": {"scalar-unit.frequency", 1000},
+	"MHz": {"scalar-unit.frequency", 1000000},
+	"GHz": {"scalar-unit.frequency", 1000000000},
+}
+
+func parseScalar(sString string) (Scalar, error) {
+	var s Scalar
 	// Check if the s has two fields (one for the value, and the other one for the unit)
 	ss := strings.Fields(sString)
 	if len(ss) > 2 {
-		return fmt.Errorf("Not a TOSCA scalar")
+		return s, fmt.Errorf("Not a TOSCA scalar")
 	}
 	re := regexp.MustCompile("^([0-9.]+)[[:blank:]]*(B|kB|KiB|MB|MiB|GB|GiB|TB|TiB|d|h|m|s|ms|us|ns|Hz|kHz|MHz|GHz)$")
 	res := re.FindStringSubmatch(sString)
 	if len(res) != 3 {
-		return fmt.Errorf("Tosca type unknown")
+		return s, fmt.Errorf("Tosca type unknown")
 	}
 	val, err := strconv.ParseFloat(res[1], 64)
 	if err != nil {
-		return fmt.Errorf("Not a number %v", res[1])
+		return s, fmt.Errorf("Not a number %v", res[1])
 	}
 	s.Value = val
 	s.Unit = res[2]
+	return s, nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface
+// Unmarshals a string of the form "scalar unit" into a Scalar, validating that scalar and unit are valid
+func (s *Scalar) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var sString string
+	err := unmarshal(&sString)
+	if err != nil {
+		return err
+	}
+	val, err := parseScalar(sString)
+	if err != nil {
+		return err
+	}
+	*s = val
 	return nil
 }
 
+// MarshalYAML implements the yaml.Marshaler interface
+// Marshals the Scalar into its "scalar unit" notation
+func (s Scalar) MarshalYAML() (interface{}, error) {
+	return s.String(), nil
+}
+
+// Class returns the scalar-unit type (size, time or frequency) of the Scalar
+// based on its unit, or an empty string if the unit is unknown.
+func (s Scalar) Class() string {
+	return scalarUnits[s.Unit].Class
+}
+
+// BaseValue returns the value of the Scalar converted into the base unit
+// of its class (bytes, seconds or hertz).
+func (s Scalar) BaseValue() float64 {
+	if u, ok := scalarUnits[s.Unit]; ok {
+		return s.Value * u.Factor
+	}
+	return s.Value
+}
+
+// String returns the Scalar in its "scalar unit" notation
+func (s Scalar) String() string {
+	return fmt.Sprintf("%s %s", strconv.FormatFloat(s.Value, 'f', -1, 64), s.Unit)
+}
+
 // Regex type used in the constraint definition (Appendix A 5.2.1)
 type Regex interface{}
@@ -16,6 +16,8 @@ limitations under the License.
 
 package toscalib
 
+import "fmt"
+
 // This implements the type defined in Appendix A 3 of the definition file
 const (
 	StateInitial     = iota // Node is not yet created. Node only exists as a template definition
@@ -26,10 +28,63 @@ const (
 	StateStarting    = iota // Node is transitioning from configured state to started state.
 	StateStarted     = iota // Node is started.
 	StateStopping    = iota // Node is transitioning from its current state to a configured state.
-	StateDeleting    = iota // Node is transitioning from its current state to one where it is deleted and its state is =iota // longer tracked by the instance model.
+	StateDeleting    = iota // Node is transitioning from its current state to one where it is deleted and its state is no longer tracked by the instance model.
 	StateError       = iota // Node is in an error state
+	StateDeleted     = iota // Node is deleted (TOSCA 1.2)
 )
 
+// stateNames are the names of the node states, as used by the set_state activities
+var stateNames = []string{"initial", "creating", "created", "configuring", "configured",
+	"starting", "started", "stopping", "deleting", "error", "deleted"}
+
+// stateTransitions are the transitions of the normative node lifecycle, a node can
+// also stay in its current state.
+var stateTransitions = map[int][]int{
+	StateInitial:     {StateCreating},
+	StateCreating:    {StateCreated, StateError},
+	StateCreated:     {StateConfiguring, StateDeleting},
+	StateConfiguring: {StateConfigured, StateError},
+	StateConfigured:  {StateStarting, StateDeleting},
+	StateStarting:    {StateStarted, StateError},
+	StateStarted:     {StateStopping},
+	StateStopping:    {StateConfigured, StateError},
+	StateDeleting:    {StateDeleted, StateInitial, StateError},
+	StateError:       {StateCreating, StateConfiguring, StateStarting, StateStopping, StateDeleting},
+	StateDeleted:     {StateCreating},
+}
+
+// StateName returns the name of a node state (ie. started)
+func StateName(state int) string {
+	if state < 0 || state >= len(stateNames) {
+		return fmt.Sprintf("unknown(%d)", state)
+	}
+	return stateNames[state]
+}
+
+// ParseState returns the node state with the given name
+func ParseState(name string) (int, error) {
+	for state, n := range stateNames {
+		if n == name {
+			return state, nil
+		}
+	}
+	return StateInitial, fmt.Errorf("Unknown node state %q", name)
+}
+
+// IsValidTransition reports whether the normative lifecycle allows a node to go
+// from a state to another.
+func IsValidTransition(from, to int) bool {
+	if from == to {
+		return true
+	}
+	for _, s := range stateTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
 const (
 	// NetworkPrivate is an alias used to reference the first private network within a property or attribute
 	// of a Node or Capability which would be assigned to them by the underlying platform at runtime.
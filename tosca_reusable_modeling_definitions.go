@@ -67,17 +67,13 @@ type ArtifactType struct {
 	Properties  map[string]PropertyDefinition `yaml:"properties,omitempty" json:"properties,omitempty"` // optional list of property definitions for the artifact type
 }
 
-// NodeFilter Appendix 5.4 TODO: Implement NodeFilter struct
-// A node filter definition defines criteria for selection of a TOSCA Node Template based upon the template’s property values, capabilities and capability properties.
-type NodeFilter interface{}
-
 // DataType as described in Appendix 6.5
 // A Data Type definition defines the schema for new named datatypes in TOSCA.
 type DataType struct {
 	DerivedFrom string                        `yaml:"derived_from,omitempty" json:"derived_from,omitempty"` // The optional key used when a datatype is derived from an existing TOSCA Data Type.
 	Description string                        `yaml:"description,omitempty" json:"description,omitempty"`   // The optional description for the Data Type.
-	Constraints Constraints                   `yaml:"constraints" json:"constraints"`                       // The optional list of sequenced constraint clauses for the Data Type.
-	Properties  map[string]PropertyDefinition `yaml:"properties" json:"properties"`                         // The optional list property definitions that comprise the schema for a complex Data Type in TOSCA.
+	Constraints Constraints                   `yaml:"constraints,omitempty" json:"constraints"`             // The optional list of sequenced constraint clauses for the Data Type.
+	Properties  map[string]PropertyDefinition `yaml:"properties,omitempty" json:"properties"`               // The optional list property definitions that comprise the schema for a complex Data Type in TOSCA.
 }
 
 // RepositoryDefinition as desribed in Appendix 5.6
@@ -0,0 +1,391 @@
+package toscalib
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Primitive TOSCA types as described in Appendix A 2
+const (
+	StringType              = "string"
+	IntegerType             = "integer"
+	FloatType               = "float"
+	BooleanType             = "boolean"
+	TimestampType           = "timestamp"
+	VersionType             = "version"
+	RangeType               = "range"
+	ListType                = "list"
+	MapType                 = "map"
+	ScalarUnitSizeType      = "scalar-unit.size"
+	ScalarUnitTimeType      = "scalar-unit.time"
+	ScalarUnitFrequencyType = "scalar-unit.frequency"
+)
+
+// timestamp formats accepted by YAML 1.1 (http://yaml.org/type/timestamp.html)
+var timestampFormats = []string{
+	time.RFC3339Nano,
+	"2006-1-2t15:4:5.999999999Z07:00",
+	"2006-1-2T15:4:5.999999999Z07:00",
+	"2006-1-2 15:4:5.999999999 -7",
+	"2006-1-2 15:4:5.999999999",
+	"2006-1-2",
+}
+
+// typeError describes a value that does not match its declared type
+type typeError struct {
+	Path    string
+	Message string
+}
+
+func (e typeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// typeChecker verifies and converts values according to the TOSCA primitive types
+// and the data types defined in the service template.
+type typeChecker struct {
+	std       *ServiceTemplateDefinition
+	dataTypes map[string]DataType
+}
+
+// newTypeChecker returns a typeChecker for the service template, its data
+// types are only flattened when a value of a non primitive type is checked.
+func newTypeChecker(s *ServiceTemplateDefinition) *typeChecker {
+	return &typeChecker{std: s}
+}
+
+func (tc *typeChecker) types() map[string]DataType {
+	if tc.dataTypes == nil && tc.std != nil {
+		tc.dataTypes = flattenHierarchy(*tc.std).DataTypes
+	}
+	return tc.dataTypes
+}
+
+// convert checks the value against the definition (type, entry schema and constraints)
+// and returns it converted into the Go type matching the TOSCA type.
+// Values that are function calls are returned untouched as they cannot be checked
+// before being evaluated.
+func (tc *typeChecker) convert(path string, def PropertyDefinition, val interface{}) (interface{}, []typeError) {
+	if val == nil || isFunctionValue(val) {
+		return val, nil
+	}
+
+	out, errs := tc.convertType(path, def.Type, def.EntrySchema, val)
+	if len(errs) != 0 {
+		return val, errs
+	}
+	return out, tc.checkConstraints(path, def.Type, def.Constraints, out)
+}
+
+func (tc *typeChecker) checkConstraints(path, typ string, constraints Constraints, val interface{}) []typeError {
+	// the constraints of a range apply to both its bounds
+	values := []interface{}{val}
+	if typ == RangeType {
+		values = toList(val)
+	}
+	for _, v := range values {
+		if ok, err := constraints.IsValid(v); !ok {
+			return []typeError{{path, err.Error()}}
+		}
+	}
+	return nil
+}
+
+func (tc *typeChecker) convertType(path, typ string, schema interface{}, val interface{}) (interface{}, []typeError) {
+	fail := func(format string, args ...interface{}) (interface{}, []typeError) {
+		return val, []typeError{{path, fmt.Sprintf(format, args...)}}
+	}
+
+	switch typ {
+	case "":
+		return val, nil
+
+	case StringType:
+		switch reflect.ValueOf(val).Kind() {
+		case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+			return fail("expected %s, got %v", typ, val)
+		}
+		return fmt.Sprintf("%v", val), nil
+
+	case IntegerType:
+		if i, ok := toInteger(val); ok {
+			return i, nil
+		}
+		return fail("expected %s, got %v", typ, val)
+
+	case FloatType:
+		if f, ok := toFloat(val); ok {
+			return f, nil
+		}
+		return fail("expected %s, got %v", typ, val)
+
+	case BooleanType:
+		if b, ok := toBool(val); ok {
+			return b, nil
+		}
+		return fail("expected %s, got %v", typ, val)
+
+	case TimestampType:
+		if ts, ok := toTimestamp(val); ok {
+			return ts, nil
+		}
+		return fail("expected %s, got %v", typ, val)
+
+	case VersionType:
+		v, err := toVersion(derefValue(val))
+		if err != nil {
+			return fail("expected %s, got %v", typ, val)
+		}
+		return Version{v}, nil
+
+	case RangeType:
+		bounds := toList(val)
+		if len(bounds) != 2 {
+			return fail("expected %s, got %v", typ, val)
+		}
+		lower, ok := toInteger(bounds[0])
+		if !ok {
+			return fail("invalid lower bound %v of %s", bounds[0], typ)
+		}
+		upper, ok := toInteger(bounds[1])
+		if s, isStr := bounds[1].(string); isStr && s == "UNBOUNDED" {
+			upper, ok = int(UNBOUNDED), true
+		}
+		if !ok {
+			return fail("invalid upper bound %v of %s", bounds[1], typ)
+		}
+		if lower > upper {
+			return fail("lower bound %d of %s is greater than its upper bound %d", lower, typ, upper)
+		}
+		return []interface{}{lower, upper}, nil
+
+	case ScalarUnitSizeType, ScalarUnitTimeType, ScalarUnitFrequencyType:
+		s, err := toScalar(derefValue(val))
+		if err != nil || s.Class() != typ {
+			return fail("expected %s, got %v", typ, val)
+		}
+		return s, nil
+
+	case ListType:
+		rv := reflect.ValueOf(val)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return fail("expected %s, got %v", typ, val)
+		}
+		entryDef, hasSchema := entrySchemaDefinition(schema)
+		out := make([]interface{}, rv.Len())
+		var errs []typeError
+		for i := 0; i < rv.Len(); i++ {
+			out[i] = rv.Index(i).Interface()
+			if hasSchema {
+				v, e := tc.convert(fmt.Sprintf("%s[%d]", path, i), entryDef, out[i])
+				out[i] = v
+				errs = append(errs, e...)
+			}
+		}
+		return out, errs
+
+	case MapType:
+		m, ok := toStringMap(val)
+		if !ok {
+			return fail("expected %s, got %v", typ, val)
+		}
+		entryDef, hasSchema := entrySchemaDefinition(schema)
+		var errs []typeError
+		if hasSchema {
+			for _, k := range sortedKeys(m) {
+				v, e := tc.convert(fmt.Sprintf("%s.%s", path, k), entryDef, m[k])
+				m[k] = v
+				errs = append(errs, e...)
+			}
+		}
+		return m, errs
+	}
+
+	return tc.convertDataType(path, typ, schema, val)
+}
+
+func (tc *typeChecker) convertDataType(path, typ string, schema interface{}, val interface{}) (interface{}, []typeError) {
+	name, dt, ok := tc.dataType(typ)
+	if !ok {
+		return val, []typeError{{path, fmt.Sprintf("unknown data type %q", typ)}}
+	}
+
+	// data types derived from a primitive type only add constraints
+	if prim := tc.primitiveOf(name); prim != "" {
+		out, errs := tc.convertType(path, prim, schema, val)
+		if len(errs) != 0 {
+			return val, errs
+		}
+		return out, tc.checkConstraints(path, prim, dt.Constraints, out)
+	}
+
+	m, ok := toStringMap(val)
+	if !ok {
+		return val, []typeError{{path, fmt.Sprintf("expected %s, got %v", typ, val)}}
+	}
+
+	var errs []typeError
+	for _, k := range sortedKeys(dt.Properties) {
+		def := dt.Properties[k]
+		v, present := m[k]
+		if !present {
+			if def.Required && def.Default == nil {
+				errs = append(errs, typeError{fmt.Sprintf("%s.%s", path, k), fmt.Sprintf("required property %q of %s has no value", k, typ)})
+			}
+			continue
+		}
+		v, e := tc.convert(fmt.Sprintf("%s.%s", path, k), def, v)
+		m[k] = v
+		errs = append(errs, e...)
+	}
+	for _, k := range sortedKeys(m) {
+		if _, ok := dt.Properties[k]; !ok {
+			errs = append(errs, typeError{fmt.Sprintf("%s.%s", path, k), fmt.Sprintf("property %q is not declared by %s", k, typ)})
+		}
+	}
+	if len(errs) != 0 {
+		return val, errs
+	}
+	return m, tc.checkConstraints(path, typ, dt.Constraints, m)
+}
+
+// dataType looks up a data type by its full name or, as allowed for the
+// normative types, by the last part of its name (ie. PortDef).
+func (tc *typeChecker) dataType(typ string) (string, DataType, bool) {
+	types := tc.types()
+	if dt, ok := types[typ]; ok {
+		return typ, dt, true
+	}
+	for _, name := range sortedKeys(types) {
+		if strings.HasSuffix(name, "."+typ) {
+			return name, types[name], true
+		}
+	}
+	return "", DataType{}, false
+}
+
+// primitiveOf returns the primitive type a data type derives from, if any
+func (tc *typeChecker) primitiveOf(typ string) string {
+	for i := 0; typ != "" && i <= len(tc.types()); i++ {
+		if isPrimitiveType(typ) {
+			return typ
+		}
+		name, dt, ok := tc.dataType(typ)
+		if !ok {
+			return ""
+		}
+		typ = dt.DerivedFrom
+		if typ == name {
+			return ""
+		}
+	}
+	return ""
+}
+
+func isPrimitiveType(typ string) bool {
+	switch typ {
+	case StringType, IntegerType, FloatType, BooleanType, TimestampType, VersionType, RangeType,
+		ListType, MapType, ScalarUnitSizeType, ScalarUnitTimeType, ScalarUnitFrequencyType:
+		return true
+	}
+	return false
+}
+
+// entrySchemaDefinition converts an entry_schema into a PropertyDefinition,
+// both its short (type name) and full notations are supported.
+func entrySchemaDefinition(schema interface{}) (PropertyDefinition, bool) {
+	var def PropertyDefinition
+	switch s := schema.(type) {
+	case nil:
+		return def, false
+	case string:
+		def.Type = s
+		return def, true
+	}
+	data, err := yaml.Marshal(schema)
+	if err != nil {
+		return def, false
+	}
+	if err := yaml.Unmarshal(data, &def); err != nil {
+		return def, false
+	}
+	return def, def.Type != ""
+}
+
+// isFunctionValue reports whether the value is a call to a TOSCA function
+func isFunctionValue(val interface{}) bool {
+	m, ok := toStringMap(val)
+	if !ok || len(m) != 1 {
+		return false
+	}
+	for k := range m {
+		return isFunction(k)
+	}
+	return false
+}
+
+func toInteger(v interface{}) (int, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		if f := rv.Float(); f == math.Trunc(f) {
+			return int(f), true
+		}
+	case reflect.String:
+		if i, err := strconv.ParseInt(rv.String(), 0, 64); err == nil {
+			return int(i), true
+		}
+	}
+	return 0, false
+}
+
+func toBool(v interface{}) (bool, bool) {
+	switch t := v.(type) {
+	case bool:
+		return t, true
+	case string:
+		switch strings.ToLower(t) {
+		case "true", "yes", "on":
+			return true, true
+		case "false", "no", "off":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+func toTimestamp(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		for _, format := range timestampFormats {
+			if ts, err := time.Parse(format, t); err == nil {
+				return ts, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func toStringMap(v interface{}) (map[string]interface{}, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map {
+		return nil, false
+	}
+	m := make(map[string]interface{}, rv.Len())
+	for _, k := range rv.MapKeys() {
+		m[fmt.Sprintf("%v", k.Interface())] = rv.MapIndex(k).Interface()
+	}
+	return m, true
+}
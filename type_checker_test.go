@@ -0,0 +1,89 @@
+package toscalib
+
+import (
+	"os"
+	"testing"
+)
+
+func TestTypeChecker(t *testing.T) {
+	tc := &typeChecker{}
+
+	type check struct {
+		typ      string
+		value    interface{}
+		expected interface{}
+	}
+	checks := []check{
+		{"string", 12, "12"},
+		{"integer", "42", 42},
+		{"float", "1.5", 1.5},
+		{"boolean", "yes", true},
+		{"range", []interface{}{1, "UNBOUNDED"}, []interface{}{1, int(UNBOUNDED)}},
+		{"scalar-unit.size", "4 GB", Scalar{Value: 4, Unit: "GB"}},
+	}
+	for _, c := range checks {
+		out, errs := tc.convert("value", PropertyDefinition{Type: c.typ}, c.value)
+		if len(errs) != 0 {
+			t.Errorf("%s %v: unexpected errors %v", c.typ, c.value, errs)
+			continue
+		}
+		if !equalValues(out, c.expected) {
+			t.Errorf("%s %v: expected %v, actual %v (%T)", c.typ, c.value, c.expected, out, out)
+		}
+	}
+
+	invalids := []check{
+		{"string", []interface{}{"a"}, nil},
+		{"integer", "1.5", nil},
+		{"float", "abc", nil},
+		{"boolean", "maybe", nil},
+		{"timestamp", "yesterday", nil},
+		{"version", "latest", nil},
+		{"range", []interface{}{2, 1}, nil},
+		{"scalar-unit.time", "4 GB", nil},
+		{"list", "a", nil},
+		{"map", []interface{}{"a"}, nil},
+		{"tosca.datatypes.Unknown", "a", nil},
+	}
+	for _, c := range invalids {
+		if _, errs := tc.convert("value", PropertyDefinition{Type: c.typ}, c.value); len(errs) == 0 {
+			t.Errorf("%s %v: expected an error", c.typ, c.value)
+		}
+	}
+}
+
+func TestValidateTypes(t *testing.T) {
+	fname := "./tests/invalids/test_type_errors.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	errs := s.Validate()
+	want := []string{
+		"topology_template.inputs.memory",
+		"topology_template.node_templates.bad.properties.enabled",
+		"topology_template.node_templates.bad.properties.limits.memory",
+		"topology_template.node_templates.bad.properties.mirrors[0].proto",
+		"topology_template.node_templates.bad.properties.ports",
+		"topology_template.node_templates.bad.properties.primary.port",
+		"topology_template.node_templates.bad.properties.primary.url",
+		"topology_template.node_templates.bad.properties.release",
+		"topology_template.node_templates.bad.properties.replicas",
+		"topology_template.node_templates.bad.properties.tags[1]",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, actual %d:\n%v", len(want), len(errs), errs)
+	}
+	for i, path := range want {
+		if errs[i].Path != path {
+			t.Errorf("expected error on %s, actual %v", path, errs[i])
+		}
+	}
+}
@@ -2,9 +2,13 @@ package toscalib
 
 import (
 	"io/ioutil"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
 )
 
 // Original source:
@@ -51,6 +55,10 @@ func _deepClone(to, from reflect.Value) {
 	// If it is a struct we translate each field
 	case reflect.Struct:
 		for i := 0; i < from.NumField(); i++ {
+			// the unexported fields are left to the caller
+			if !to.Field(i).CanSet() {
+				continue
+			}
 			_deepClone(to.Field(i), from.Field(i))
 		}
 
@@ -105,26 +113,21 @@ func get(k int, list []interface{}) string {
 	return ""
 }
 
-func remainder(k int, list []interface{}) []interface{} {
-	if len(list) <= k {
-		return make([]interface{}, 0)
-	}
-	return list[k+1:]
-}
-
 func copyFile(src, destDir string) (string, error) {
 	absSrc, err := filepath.Abs(src)
 	if err != nil {
 		return "", err
 	}
-	srcFilename := filepath.Base(absSrc)
-
-	dest, err := filepath.Abs(filepath.Join(destDir, srcFilename))
+	data, err := ioutil.ReadFile(absSrc)
 	if err != nil {
 		return "", err
 	}
+	return writeFile(data, filepath.Base(absSrc), destDir)
+}
 
-	data, err := ioutil.ReadFile(absSrc)
+// writeFile writes data to the named file of destDir and returns its absolute path
+func writeFile(data []byte, name, destDir string) (string, error) {
+	dest, err := filepath.Abs(filepath.Join(destDir, name))
 	if err != nil {
 		return "", err
 	}
@@ -144,3 +147,42 @@ func isAbsLocalPath(location string) bool {
 	}
 	return false
 }
+
+// canonicalLocation returns a location identifying a document whatever the way it
+// is referenced: the local paths are absolute and cleaned, the scheme and the host
+// of the URLs are lowercased and their path is cleaned.
+func canonicalLocation(location string) string {
+	u, err := url.Parse(location)
+	if err != nil || len(u.Scheme) <= 1 {
+		if abs, err := filepath.Abs(location); err == nil {
+			return abs
+		}
+		return filepath.Clean(location)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "file":
+		return canonicalLocation(filepath.FromSlash(u.Path))
+	case "http", "https":
+		u.Scheme, u.Host = strings.ToLower(u.Scheme), strings.ToLower(u.Host)
+		if u.Path != "" {
+			u.Path = path.Clean(u.Path)
+		}
+		return u.String()
+	}
+	return location
+}
+
+// sortedKeys returns the keys of a map indexed by strings in sorted order so
+// that the processing of the map is deterministic.
+func sortedKeys(m interface{}) []string {
+	rv := reflect.ValueOf(m)
+	if rv.Kind() != reflect.Map {
+		return nil
+	}
+	keys := make([]string, 0, rv.Len())
+	for _, k := range rv.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,506 @@
+package toscalib
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity indicates how serious a problem reported by Validate is
+type Severity int
+
+// Valid values for Severity
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// ValidationError describes a single semantic problem found in a Service Template.
+// Path is the YAML path of the offending element, for example
+// topology_template.node_templates.web_app.requirements[0].host, and Position
+// its location (or the one of its closest parent) in the source document.
+type ValidationError struct {
+	Path     string
+	Severity Severity
+	Message  string
+	Position SourcePosition
+}
+
+func (e ValidationError) Error() string {
+	if pos := e.Position.String(); pos != "" {
+		return fmt.Sprintf("%s: %s: %s: %s", pos, e.Severity, e.Path, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Severity, e.Path, e.Message)
+}
+
+// ValidationErrors is the list of problems returned by Validate
+type ValidationErrors []ValidationError
+
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, e := range v {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// HasErrors returns true if at least one problem has the SeverityError severity
+func (v ValidationErrors) HasErrors() bool {
+	for _, e := range v {
+		if e.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+type byPath ValidationErrors
+
+func (b byPath) Len() int           { return len(b) }
+func (b byPath) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byPath) Less(i, j int) bool { return b[i].Path < b[j].Path }
+
+type validator struct {
+	std  *ServiceTemplateDefinition
+	ft   flatTypes
+	tc   *typeChecker
+	errs ValidationErrors
+}
+
+func (v *validator) add(sev Severity, path, format string, args ...interface{}) {
+	v.errs = append(v.errs, v.std.newValidationError(sev, path, fmt.Sprintf(format, args...)))
+}
+
+func (s *ServiceTemplateDefinition) newValidationError(sev Severity, path, msg string) ValidationError {
+	return ValidationError{
+		Path:     path,
+		Severity: sev,
+		Message:  msg,
+		Position: s.closestPosition(path),
+	}
+}
+
+// closestPosition returns the position of the element, or of its closest parent
+// when the element itself was not indexed (ie. a flow mapping).
+func (s *ServiceTemplateDefinition) closestPosition(path string) SourcePosition {
+	for path != "" {
+		if pos, ok := s.Position(path); ok {
+			return pos
+		}
+		path = path[:strings.LastIndexAny(path, ".[")+1]
+		path = strings.TrimRight(path, ".[")
+	}
+	return SourcePosition{}
+}
+
+// Validate walks the resolved topology of a parsed Service Template and reports
+// every semantic problem found: unknown types, requirements pointing at missing
+// node templates, undeclared or missing required properties, get_input calls
+// referencing unknown inputs and values not matching their type or violating
+// their constraints.
+// It returns nil when no problem is found.
+func (s *ServiceTemplateDefinition) Validate() ValidationErrors {
+	v := &validator{std: s, ft: flattenHierarchy(*s)}
+	v.tc = &typeChecker{dataTypes: v.ft.DataTypes}
+
+	v.validateInputs()
+	for _, name := range sortedKeys(s.TopologyTemplate.NodeTemplates) {
+		v.validateNodeTemplate(name, s.TopologyTemplate.NodeTemplates[name])
+	}
+	for _, name := range sortedKeys(s.TopologyTemplate.RelationshipTemplates) {
+		v.validateRelationshipTemplate(name, s.TopologyTemplate.RelationshipTemplates[name])
+	}
+	for _, name := range sortedKeys(s.TopologyTemplate.Groups) {
+		v.validateGroup(name, s.TopologyTemplate.Groups[name])
+	}
+	for i, policies := range s.TopologyTemplate.Policies {
+		for _, name := range sortedKeys(policies) {
+			v.validatePolicy(i, name, policies[name])
+		}
+	}
+	for _, name := range sortedKeys(s.TopologyTemplate.Outputs) {
+		v.checkInputRefs(fmt.Sprintf("topology_template.outputs.%s.value", name), s.TopologyTemplate.Outputs[name].Value.Assignment)
+	}
+	for _, name := range sortedKeys(s.TopologyTemplate.Workflows) {
+		v.validateWorkflow(name, s.TopologyTemplate.Workflows[name])
+	}
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+	sort.Stable(byPath(v.errs))
+	return v.errs
+}
+
+// validateWorkflow reports the steps linked to unknown steps, targeting unknown
+// Node Templates, groups or requirements, calling operations not declared by their
+// target, setting non normative states or inlining unknown workflows, as well as
+// the cycles between the steps.
+func (v *validator) validateWorkflow(name string, wf WorkflowDefinition) {
+	path := fmt.Sprintf("topology_template.workflows.%s", name)
+
+	for _, sname := range sortedKeys(wf.Steps) {
+		step := wf.Steps[sname]
+		spath := fmt.Sprintf("%s.steps.%s", path, sname)
+
+		for i, next := range step.OnSuccess {
+			if _, ok := wf.Steps[next]; !ok {
+				v.add(SeverityError, fmt.Sprintf("%s.on_success[%d]", spath, i), "step %q not found", next)
+			}
+		}
+		for i, next := range step.OnFailure {
+			if _, ok := wf.Steps[next]; !ok {
+				v.add(SeverityError, fmt.Sprintf("%s.on_failure[%d]", spath, i), "step %q not found", next)
+			}
+		}
+
+		nodes, ok := v.stepTargets(spath, step)
+		if !ok {
+			continue
+		}
+		for i, act := range step.Activities {
+			apath := fmt.Sprintf("%s.activities[%d]", spath, i)
+			switch {
+			case act.SetState != "":
+				if !contains(stateNames, act.SetState) {
+					v.add(SeverityError, apath+".set_state", "%q is not a normative node state", act.SetState)
+				}
+			case act.CallOperation != "":
+				v.checkCallOperation(apath+".call_operation", step, nodes, act.CallOperation)
+			case act.Inline != "":
+				if _, ok := v.std.TopologyTemplate.Workflows[act.Inline]; !ok {
+					v.add(SeverityError, apath+".inline", "workflow %q not found", act.Inline)
+				} else if cycle := v.std.inlineCycle(name, act.Inline); cycle != nil {
+					v.add(SeverityError, apath+".inline", "inline cycle detected: %s", strings.Join(cycle, " -> "))
+				}
+			}
+		}
+	}
+
+	if cycle := wf.findCycle(); cycle != nil {
+		v.add(SeverityError, path+".steps", "steps cycle detected: %s", strings.Join(cycle, " -> "))
+	}
+}
+
+// stepTargets returns the Node Templates targeted by a step, the members of the
+// group when it targets a group.
+func (v *validator) stepTargets(path string, step StepDefinition) ([]string, bool) {
+	if nt := v.std.GetNodeTemplate(step.Target); nt != nil {
+		if step.TargetRelationship != "" && nt.GetRequirement(step.TargetRelationship) == nil {
+			v.add(SeverityError, path+".target_relationship", "node template %q has no requirement %q", step.Target, step.TargetRelationship)
+			return nil, false
+		}
+		return []string{step.Target}, true
+	}
+	if gd, ok := v.std.TopologyTemplate.Groups[step.Target]; ok {
+		if step.TargetRelationship != "" {
+			v.add(SeverityError, path+".target_relationship", "target_relationship is not allowed for group %q", step.Target)
+			return nil, false
+		}
+		return gd.Members, true
+	}
+	v.add(SeverityError, path+".target", "node template or group %q not found", step.Target)
+	return nil, false
+}
+
+// checkCallOperation reports the operations, given as <interface>.<operation>,
+// not declared by the Node Templates or by the relationship targeted by the step.
+func (v *validator) checkCallOperation(path string, step StepDefinition, nodes []string, call string) {
+	idx := strings.LastIndex(call, ".")
+	if idx <= 0 || idx == len(call)-1 {
+		v.add(SeverityError, path, "invalid operation %q, expected <interface>.<operation>", call)
+		return
+	}
+	intf, op := call[:idx], call[idx+1:]
+
+	for _, node := range nodes {
+		nt := v.std.GetNodeTemplate(node)
+		if nt == nil {
+			continue
+		}
+		intfs := nt.Interfaces
+		if step.TargetRelationship != "" {
+			intfs = v.std.relationshipInterfaces(node, step.TargetRelationship, v.std.requirementRelationship(nt, step.TargetRelationship))
+		} else if ntype, ok := v.ft.Nodes[nt.Type]; ok && len(intfs) == 0 {
+			intfs = ntype.Interfaces
+		}
+		if _, ok := findOperation(intfs, intf, op); !ok {
+			v.add(SeverityError, path, "operation %q is not declared by %q", call, node)
+		}
+	}
+}
+
+func (v *validator) validateInputs() {
+	for _, name := range sortedKeys(v.std.TopologyTemplate.Inputs) {
+		def := v.std.TopologyTemplate.Inputs[name]
+		path := fmt.Sprintf("topology_template.inputs.%s", name)
+
+		val := def.Value.Value
+		if val == nil {
+			val = def.Default
+		}
+		if val == nil || def.Value.Function != "" {
+			continue
+		}
+		v.checkValue(path, def, val)
+	}
+}
+
+func (v *validator) validateNodeTemplate(name string, nt NodeTemplate) {
+	path := fmt.Sprintf("topology_template.node_templates.%s", name)
+
+	if nt.Type == "" {
+		v.add(SeverityError, path+".type", "missing node type")
+		return
+	}
+	ntype, ok := v.ft.Nodes[nt.Type]
+	if !ok {
+		v.add(SeverityError, path+".type", "unknown node type %q", nt.Type)
+		return
+	}
+
+	v.checkProperties(path+".properties", nt.Properties, ntype.Properties, SeverityError)
+
+	for _, capname := range sortedKeys(nt.Capabilities) {
+		cpath := fmt.Sprintf("%s.capabilities.%s", path, capname)
+		cd, ok := ntype.Capabilities[capname]
+		if !ok {
+			v.add(SeverityError, cpath, "capability %q is not declared by node type %q", capname, nt.Type)
+			continue
+		}
+		// the normative definitions reference some capability types that are not
+		// defined, in which case the declared properties are unknown.
+		if _, known := v.ft.Capabilities[cd.Type]; !known {
+			continue
+		}
+		v.checkProperties(cpath+".properties", nt.Capabilities[capname].Properties, cd.Properties, SeverityError)
+	}
+
+	for i, reqs := range nt.Requirements {
+		for _, rname := range sortedKeys(reqs) {
+			v.validateRequirement(fmt.Sprintf("%s.requirements[%d].%s", path, i, rname), rname, reqs[rname], ntype)
+		}
+	}
+
+	for _, iname := range sortedKeys(nt.Interfaces) {
+		v.checkInterface(fmt.Sprintf("%s.interfaces.%s", path, iname), nt.Interfaces[iname])
+	}
+
+	for _, aname := range sortedKeys(nt.Attributes) {
+		// properties are reflected as attributes, they were already checked
+		if _, ok := nt.Properties[aname]; ok {
+			continue
+		}
+		v.checkInputRefs(fmt.Sprintf("%s.attributes.%s", path, aname), nt.Attributes[aname].Assignment)
+	}
+}
+
+func (v *validator) validateRequirement(path, name string, req RequirementAssignment, ntype NodeType) {
+	declared := false
+	for _, defs := range ntype.Requirements {
+		if _, ok := defs[name]; ok {
+			declared = true
+		}
+	}
+	if !declared {
+		v.add(SeverityWarning, path, "requirement %q is not declared by the node type", name)
+	}
+
+	for _, pname := range sortedKeys(req.Relationship.Properties) {
+		v.checkInputRefs(fmt.Sprintf("%s.relationship.properties.%s", path, pname), req.Relationship.Properties[pname].Assignment)
+	}
+
+	// values inherited as-is from the requirement definition of the node type
+	// are not checked as they do not come from the template itself.
+	rd := ntype.getRequirement(name)
+
+	if rt := req.Relationship.Type; rt != "" && rt != rd.Relationship.Type {
+		_, isType := v.ft.Relationships[rt]
+		_, isTemplate := v.std.TopologyTemplate.RelationshipTemplates[rt]
+		if !isType && !isTemplate {
+			v.add(SeverityError, path+".relationship", "unknown relationship %q", rt)
+		}
+	}
+
+	if req.Node == "" || req.Node == rd.Node {
+		return
+	}
+	target := v.std.GetNodeTemplate(req.Node)
+	if target == nil {
+		if _, isType := v.ft.Nodes[req.Node]; !isType {
+			v.add(SeverityError, path+".node", "node template %q not found", req.Node)
+		}
+		return
+	}
+
+	if req.Capability != "" && req.Capability != rd.Capability {
+		if !v.hasCapability(v.ft.Nodes[target.Type], req.Capability) {
+			v.add(SeverityError, path+".capability", "node template %q does not provide capability %q", req.Node, req.Capability)
+		}
+	}
+}
+
+func (v *validator) hasCapability(ntype NodeType, capability string) bool {
+	_, ok := v.std.findCapability(ntype, capability)
+	return ok
+}
+
+func (v *validator) validateRelationshipTemplate(name string, rt RelationshipTemplate) {
+	path := fmt.Sprintf("topology_template.relationship_templates.%s", name)
+
+	rtype, ok := v.ft.Relationships[rt.Type]
+	if !ok {
+		v.add(SeverityError, path+".type", "unknown relationship type %q", rt.Type)
+		return
+	}
+
+	v.checkProperties(path+".properties", rt.Properties, rtype.Properties, SeverityError)
+	for _, iname := range sortedKeys(rt.Interfaces) {
+		v.checkInterface(fmt.Sprintf("%s.interfaces.%s", path, iname), rt.Interfaces[iname])
+	}
+}
+
+func (v *validator) validateGroup(name string, gd GroupDefinition) {
+	path := fmt.Sprintf("topology_template.groups.%s", name)
+
+	gtype, ok := v.ft.Groups[gd.Type]
+	if !ok {
+		v.add(SeverityError, path+".type", "unknown group type %q", gd.Type)
+		return
+	}
+
+	for i, m := range gd.Members {
+		if v.std.GetNodeTemplate(m) == nil {
+			v.add(SeverityError, fmt.Sprintf("%s.members[%d]", path, i), "node template %q not found", m)
+		}
+	}
+	// the normative group and policy types do not declare the properties
+	// expected by orchestrators, so undeclared ones are only a warning.
+	v.checkProperties(path+".properties", gd.Properties, gtype.Properties, SeverityWarning)
+}
+
+func (v *validator) validatePolicy(idx int, name string, pd PolicyDefinition) {
+	path := fmt.Sprintf("topology_template.policies[%d].%s", idx, name)
+
+	ptype, ok := v.ft.Policies[pd.Type]
+	if !ok {
+		v.add(SeverityError, path+".type", "unknown policy type %q", pd.Type)
+		return
+	}
+
+	for i, target := range pd.Targets {
+		_, isNode := v.std.TopologyTemplate.NodeTemplates[target]
+		_, isGroup := v.std.TopologyTemplate.Groups[target]
+		if !isNode && !isGroup {
+			v.add(SeverityError, fmt.Sprintf("%s.targets[%d]", path, i), "node template or group %q not found", target)
+		}
+	}
+	v.checkProperties(path+".properties", pd.Properties, ptype.Properties, SeverityWarning)
+}
+
+// checkProperties reports properties assigned without being declared (using the
+// given severity), properties declared as required that have no value and
+// get_input calls to unknown inputs. Assigned values are checked against the
+// type of their definition.
+func (v *validator) checkProperties(path string, props map[string]PropertyAssignment, defs map[string]PropertyDefinition, undeclared Severity) {
+	for _, name := range sortedKeys(props) {
+		pa := props[name]
+		def, ok := defs[name]
+		if !ok {
+			v.add(undeclared, fmt.Sprintf("%s.%s", path, name), "property %q is not declared", name)
+		} else if !pa.isEmpty() && pa.Function == "" && pa.Expression.Operator == "" {
+			v.checkValue(fmt.Sprintf("%s.%s", path, name), def, pa.Value)
+		}
+		v.checkInputRefs(fmt.Sprintf("%s.%s", path, name), pa.Assignment)
+	}
+
+	for _, name := range sortedKeys(defs) {
+		if !defs[name].Required {
+			continue
+		}
+		if pa, ok := props[name]; !ok || pa.isEmpty() {
+			v.add(SeverityError, fmt.Sprintf("%s.%s", path, name), "required property %q has no value", name)
+		}
+	}
+}
+
+// checkValue reports the mismatches between a value and the type, entry schema
+// and constraints of its definition.
+func (v *validator) checkValue(path string, def PropertyDefinition, val interface{}) {
+	_, errs := v.tc.convert(path, def, val)
+	for _, e := range errs {
+		v.add(SeverityError, e.Path, "%s", e.Message)
+	}
+}
+
+func (v *validator) checkInterface(path string, intf InterfaceDefinition) {
+	for _, name := range sortedKeys(intf.Inputs) {
+		v.checkInputRefs(fmt.Sprintf("%s.inputs.%s", path, name), intf.Inputs[name].Assignment)
+	}
+	for _, opname := range sortedKeys(intf.Operations) {
+		op := intf.Operations[opname]
+		for _, name := range sortedKeys(op.Inputs) {
+			v.checkInputRefs(fmt.Sprintf("%s.%s.inputs.%s", path, opname, name), op.Inputs[name].Assignment)
+		}
+	}
+}
+
+func (v *validator) checkInputRefs(path string, a Assignment) {
+	for _, name := range a.inputRefs() {
+		if _, ok := v.std.TopologyTemplate.Inputs[name]; !ok {
+			v.add(SeverityError, path, "get_input references unknown input %q", name)
+		}
+	}
+}
+
+// inputRefs returns the names of all inputs referenced by get_input, including
+// the calls nested within other functions.
+func (p *Assignment) inputRefs() []string {
+	var refs []string
+	if p.Function == GetInputFunc {
+		if name := get(0, p.Args); name != "" {
+			refs = append(refs, name)
+		}
+		return refs
+	}
+	for _, arg := range p.Args {
+		if pa := newAssignmentFunc(arg); pa != nil {
+			refs = append(refs, pa.inputRefs()...)
+		}
+	}
+	return refs
+}
+
+func (p *Assignment) isEmpty() bool {
+	if p.Function != "" || p.Expression.Operator != "" {
+		return false
+	}
+	if s, ok := p.Value.(string); ok {
+		return s == ""
+	}
+	return p.Value == nil
+}
+
+func (s *ServiceTemplateDefinition) capabilityTypeHierarchy(name string) []string {
+	var types []string
+	typeName := name
+	for typeName != "" {
+		if ct, ok := s.CapabilityTypes[typeName]; ok {
+			types = append(types, typeName)
+			typeName = ct.DerivedFrom
+		} else {
+			typeName = ""
+		}
+	}
+	return types
+}
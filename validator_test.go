@@ -0,0 +1,63 @@
+package toscalib
+
+import (
+	"os"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	fname := "./tests/tosca_web_application.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	if errs := s.Validate(); len(errs) != 0 {
+		t.Log(fname, "valid template reported errors")
+		t.Fatal(errs)
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	fname := "./tests/invalids/test_semantic_errors.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	errs := s.Validate()
+	if !errs.HasErrors() {
+		t.Fatal(fname, "invalid template did not report any error")
+	}
+
+	want := []string{
+		"topology_template.inputs.cpus",
+		"topology_template.node_templates.server.type",
+		"topology_template.node_templates.web_app.properties.context_root",
+		"topology_template.node_templates.web_app.properties.unknown_prop",
+		"topology_template.node_templates.web_app.requirements[0].host.node",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, actual %d:\n%v", len(want), len(errs), errs)
+	}
+	for i, path := range want {
+		if errs[i].Path != path {
+			t.Errorf("expected error on %s, actual %v", path, errs[i])
+		}
+		if errs[i].Severity != SeverityError {
+			t.Errorf("expected %v to be an error", errs[i])
+		}
+	}
+}
@@ -10,10 +10,12 @@ type WorkflowDefinition struct {
 
 // StepDefinition structure to handle workflow steps
 type StepDefinition struct {
-	Target     string               `yaml:"target,omitempty" json:"target,omitempty"`
-	OnSuccess  []string             `yaml:"on_success,omitempty" json:"on_success,omitempty"`
-	Activities []ActivityDefinition `yaml:"activities,omitempty" json:"activities,omitempty"`
-	Filter     Filter               `yaml:"filter,omitempty" json:"filter,omitempty"`
+	Target             string               `yaml:"target,omitempty" json:"target,omitempty"`
+	TargetRelationship string               `yaml:"target_relationship,omitempty" json:"target_relationship,omitempty"`
+	OnSuccess          []string             `yaml:"on_success,omitempty" json:"on_success,omitempty"`
+	OnFailure          []string             `yaml:"on_failure,omitempty" json:"on_failure,omitempty"`
+	Activities         []ActivityDefinition `yaml:"activities,omitempty" json:"activities,omitempty"`
+	Filter             Filter               `yaml:"filter,omitempty" json:"filter,omitempty"`
 }
 
 // ActivityDefinition structure to handle workflow step activity
@@ -0,0 +1,263 @@
+package toscalib
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Names of the default workflows
+const (
+	DeployWorkflow   = "deploy"
+	UndeployWorkflow = "undeploy"
+)
+
+// Names of the normative interfaces used by the default workflows
+const (
+	StandardInterface  = "Standard"
+	ConfigureInterface = "Configure"
+)
+
+const hostedOnRelationship = "tosca.relationships.HostedOn"
+
+// workflowBuilder accumulates the steps of a workflow and the ordering between them
+type workflowBuilder struct {
+	steps map[string]StepDefinition
+}
+
+func (w *workflowBuilder) step(name, target, relationship string, activities ...ActivityDefinition) {
+	w.steps[name] = StepDefinition{Target: target, TargetRelationship: relationship, Activities: activities}
+}
+
+// order adds the step "to" to the on_success of the step "from"
+func (w *workflowBuilder) order(from, to string) {
+	st, ok := w.steps[from]
+	if !ok || contains(st.OnSuccess, to) {
+		return
+	}
+	st.OnSuccess = append(st.OnSuccess, to)
+	sort.Strings(st.OnSuccess)
+	w.steps[from] = st
+}
+
+func (w *workflowBuilder) workflow(description string) WorkflowDefinition {
+	return WorkflowDefinition{Description: description, Steps: w.steps}
+}
+
+func setState(state string) ActivityDefinition {
+	return ActivityDefinition{SetState: state}
+}
+
+func callOperation(intf, op string) ActivityDefinition {
+	return ActivityDefinition{CallOperation: fmt.Sprintf("%s.%s", intf, op)}
+}
+
+// DefaultWorkflows generates the deploy and undeploy workflows of the topology as
+// described in TOSCA 1.2 (7.2 Declarative workflows).
+// Each Node Template gets a step per operation of the Standard interface, setting
+// its state and calling the operation when it has an implementation. The steps are
+// ordered from the relationships of the dependency graph: a Node Template is created
+// once its host is started and configured once the Node Templates it depends on (or
+// connects to) are started, the pre/post configure operations of the Configure
+// interface of the relationships running around the configuration of their source
+// and target. The undeploy workflow stops and deletes the Node Templates in the
+// reverse order. A *CycleError is returned when the Node Templates depend on each other.
+func (s *ServiceTemplateDefinition) DefaultWorkflows() (map[string]WorkflowDefinition, error) {
+	g := s.DependencyGraph()
+	if cycle := g.FindCycle(); cycle != nil {
+		return nil, &CycleError{Cycle: cycle}
+	}
+
+	return map[string]WorkflowDefinition{
+		DeployWorkflow:   s.deployWorkflow(g),
+		UndeployWorkflow: s.undeployWorkflow(g),
+	}, nil
+}
+
+// AddDefaultWorkflows adds the default workflows to the topology, the workflows
+// already defined by the template are kept.
+func (s *ServiceTemplateDefinition) AddDefaultWorkflows() error {
+	wfs, err := s.DefaultWorkflows()
+	if err != nil {
+		return err
+	}
+	if s.TopologyTemplate.Workflows == nil {
+		s.TopologyTemplate.Workflows = make(map[string]WorkflowDefinition)
+	}
+	for name, wf := range wfs {
+		if _, ok := s.TopologyTemplate.Workflows[name]; !ok {
+			s.TopologyTemplate.Workflows[name] = wf
+		}
+	}
+	return nil
+}
+
+// nodeStep adds the step running an operation of the Standard interface on a
+// Node Template, surrounded by the states it goes through.
+func (s *ServiceTemplateDefinition) nodeStep(w *workflowBuilder, node, op, during, after string) {
+	activities := []ActivityDefinition{setState(during)}
+	if nt := s.GetNodeTemplate(node); nt != nil && hasOperation(nt.Interfaces, StandardInterface, op) {
+		activities = append(activities, callOperation(StandardInterface, op))
+	}
+	activities = append(activities, setState(after))
+	w.step(fmt.Sprintf("%s_%s", node, op), node, "", activities...)
+}
+
+func (s *ServiceTemplateDefinition) deployWorkflow(g *DependencyGraph) WorkflowDefinition {
+	w := &workflowBuilder{steps: make(map[string]StepDefinition)}
+
+	for _, node := range g.Nodes {
+		s.nodeStep(w, node, "create", "creating", "created")
+		s.nodeStep(w, node, "configure", "configuring", "configured")
+		s.nodeStep(w, node, "start", "starting", "started")
+		w.order(node+"_create", node+"_configure")
+		w.order(node+"_configure", node+"_start")
+	}
+
+	for _, e := range g.Edges {
+		hosted := s.isHostedOn(e.Relationship)
+		if hosted {
+			w.order(e.Target+"_start", e.Source+"_create")
+		} else {
+			w.order(e.Target+"_start", e.Source+"_configure")
+		}
+
+		intfs := s.relationshipInterfaces(e.Source, e.Requirement, e.Relationship)
+		for _, hook := range []string{"pre_configure_source", "pre_configure_target", "post_configure_source", "post_configure_target"} {
+			if !hasOperation(intfs, ConfigureInterface, hook) {
+				continue
+			}
+			name := fmt.Sprintf("%s_%s_%s", e.Source, e.Requirement, hook)
+			w.step(name, e.Source, e.Requirement, callOperation(ConfigureInterface, hook))
+
+			// the hooks run around the configuration of their node, the source
+			// one for the hooks of the host relationship as the host is started
+			// before the source is created.
+			node := e.Source
+			if !hosted && (hook == "pre_configure_target" || hook == "post_configure_target") {
+				node = e.Target
+			}
+			w.order(e.Source+"_create", name)
+			w.order(e.Target+"_create", name)
+			if hook == "pre_configure_source" || hook == "pre_configure_target" {
+				w.order(name, node+"_configure")
+			} else {
+				w.order(node+"_configure", name)
+				w.order(name, node+"_start")
+			}
+		}
+	}
+
+	return w.workflow("Default deploy workflow")
+}
+
+func (s *ServiceTemplateDefinition) undeployWorkflow(g *DependencyGraph) WorkflowDefinition {
+	w := &workflowBuilder{steps: make(map[string]StepDefinition)}
+
+	for _, node := range g.Nodes {
+		s.nodeStep(w, node, "stop", "stopping", "configured")
+		s.nodeStep(w, node, "delete", "deleting", "deleted")
+		w.order(node+"_stop", node+"_delete")
+	}
+
+	for _, e := range g.Edges {
+		if s.isHostedOn(e.Relationship) {
+			w.order(e.Source+"_delete", e.Target+"_stop")
+		} else {
+			w.order(e.Source+"_stop", e.Target+"_stop")
+		}
+	}
+
+	return w.workflow("Default undeploy workflow")
+}
+
+// isHostedOn reports whether the Relationship Type is or derives from HostedOn
+func (s *ServiceTemplateDefinition) isHostedOn(relationship string) bool {
+	for i := 0; relationship != "" && i <= len(s.RelationshipTypes); i++ {
+		if relationship == hostedOnRelationship {
+			return true
+		}
+		relationship = s.RelationshipTypes[relationship].DerivedFrom
+	}
+	return false
+}
+
+// requirementRelationship returns the Relationship Type of a requirement of the
+// Node Template, resolving the Relationship Template it may reference.
+func (s *ServiceTemplateDefinition) requirementRelationship(nt *NodeTemplate, requirement string) string {
+	req := nt.GetRequirement(requirement)
+	if req == nil {
+		return ""
+	}
+	rel := req.Relationship.Type
+	if rt, ok := s.TopologyTemplate.RelationshipTemplates[rel]; ok {
+		rel = rt.Type
+	}
+	if rel == "" {
+		rel = "tosca.relationships.DependsOn"
+	}
+	return rel
+}
+
+// relationshipInterfaces returns the interfaces of the relationship of a requirement,
+// as assigned by the requirement, its Relationship Template or its Relationship Type.
+func (s *ServiceTemplateDefinition) relationshipInterfaces(source, requirement, relationship string) map[string]InterfaceDefinition {
+	intfs := make(map[string]InterfaceDefinition)
+	add := func(from map[string]InterfaceDefinition) {
+		for name, intf := range from {
+			// merge into a copy to leave the operations of the templates untouched
+			merged := InterfaceDefinition{
+				Type:       intf.Type,
+				Inputs:     make(map[string]PropertyAssignment),
+				Operations: make(map[string]OperationDefinition),
+			}
+			for k, v := range intf.Inputs {
+				merged.Inputs[k] = v
+			}
+			for op, def := range intf.Operations {
+				inputs := make(map[string]PropertyAssignment, len(def.Inputs))
+				for k, v := range def.Inputs {
+					inputs[k] = v
+				}
+				def.Inputs = inputs
+				merged.Operations[op] = def
+			}
+			if cur, ok := intfs[name]; ok {
+				merged.merge(cur)
+			}
+			intfs[name] = merged
+		}
+	}
+
+	add(flattenRelType(relationship, *s).Interfaces)
+	if nt := s.GetNodeTemplate(source); nt != nil {
+		if req := nt.GetRequirement(requirement); req != nil {
+			if rt, ok := s.TopologyTemplate.RelationshipTemplates[req.Relationship.Type]; ok {
+				add(rt.Interfaces)
+			}
+			add(req.Relationship.Interfaces)
+		}
+	}
+	return intfs
+}
+
+// hasOperation reports whether the interfaces define an implementation for the
+// operation, the interface being given by its name or its type.
+func hasOperation(intfs map[string]InterfaceDefinition, intf, op string) bool {
+	o, ok := findOperation(intfs, intf, op)
+	return ok && o.Implementation != ""
+}
+
+// findOperation returns the definition of the operation declared by the interfaces,
+// the interface being given by its name or its type.
+func findOperation(intfs map[string]InterfaceDefinition, intf, op string) (OperationDefinition, bool) {
+	for _, name := range sortedKeys(intfs) {
+		def := intfs[name]
+		if !sameInterface(name, intf) && !sameInterface(def.Type, intf) {
+			continue
+		}
+		if o, ok := def.Operations[op]; ok {
+			return o, true
+		}
+	}
+	return OperationDefinition{}, false
+}
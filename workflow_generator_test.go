@@ -0,0 +1,141 @@
+package toscalib
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDefaultWorkflows(t *testing.T) {
+	fname := "./tests/tosca_single_instance_wordpress.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	wfs, err := s.DefaultWorkflows()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deploy, ok := wfs[DeployWorkflow]
+	if !ok {
+		t.Fatal("missing deploy workflow")
+	}
+	if len(deploy.Steps) != 15 {
+		t.Errorf("expected 15 deploy steps, got %d", len(deploy.Steps))
+	}
+
+	step := deploy.Steps["mysql_dbms_create"]
+	expected := []ActivityDefinition{
+		{SetState: "creating"},
+		{CallOperation: "Standard.create"},
+		{SetState: "created"},
+	}
+	if step.Target != "mysql_dbms" || !reflect.DeepEqual(step.Activities, expected) {
+		t.Errorf("unexpected step mysql_dbms_create %v", step)
+	}
+	if acts := deploy.Steps["server_create"].Activities; len(acts) != 2 {
+		t.Errorf("expected no operation call for server_create, got %v", acts)
+	}
+
+	onSuccess := map[string][]string{
+		"server_start":         {"mysql_dbms_create", "webserver_create"},
+		"mysql_dbms_start":     {"mysql_database_create"},
+		"mysql_database_start": {"wordpress_configure"},
+		"wordpress_create":     {"wordpress_configure"},
+		"wordpress_start":      nil,
+	}
+	for name, next := range onSuccess {
+		if actual := deploy.Steps[name].OnSuccess; !reflect.DeepEqual(actual, next) {
+			t.Errorf("expected %s to be followed by %v, actual %v", name, next, actual)
+		}
+	}
+
+	undeploy := wfs[UndeployWorkflow]
+	onSuccess = map[string][]string{
+		"wordpress_stop":   {"mysql_database_stop", "wordpress_delete"},
+		"wordpress_delete": {"webserver_stop"},
+		"webserver_delete": {"server_stop"},
+		"server_delete":    nil,
+	}
+	for name, next := range onSuccess {
+		if actual := undeploy.Steps[name].OnSuccess; !reflect.DeepEqual(actual, next) {
+			t.Errorf("expected %s to be followed by %v, actual %v", name, next, actual)
+		}
+	}
+
+	s.TopologyTemplate.Workflows = map[string]WorkflowDefinition{DeployWorkflow: {Description: "custom"}}
+	if err := s.AddDefaultWorkflows(); err != nil {
+		t.Fatal(err)
+	}
+	if s.TopologyTemplate.Workflows[DeployWorkflow].Description != "custom" {
+		t.Error("the deploy workflow of the template has been replaced")
+	}
+	if _, ok := s.TopologyTemplate.Workflows[UndeployWorkflow]; !ok {
+		t.Error("missing undeploy workflow")
+	}
+}
+
+func TestDefaultWorkflowsConfigureHooks(t *testing.T) {
+	fname := "./tests/get_property_source_target_keywords.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	wfs, err := s.DefaultWorkflows()
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := wfs[DeployWorkflow].Steps
+	hook, ok := steps["mysql_host_pre_configure_source"]
+	if !ok {
+		t.Fatal("missing pre_configure_source step")
+	}
+	if hook.Target != "mysql" || hook.TargetRelationship != "host" {
+		t.Errorf("unexpected target of the hook %v", hook)
+	}
+	if !reflect.DeepEqual(hook.OnSuccess, []string{"mysql_configure"}) {
+		t.Errorf("expected the hook to run before mysql_configure, actual %v", hook.OnSuccess)
+	}
+	for _, name := range []string{"mysql_create", "db_server_create"} {
+		if !contains(steps[name].OnSuccess, "mysql_host_pre_configure_source") {
+			t.Errorf("expected the hook to run after %s", name)
+		}
+	}
+	if _, ok := steps["mysql_host_post_configure_source"]; ok {
+		t.Error("unexpected step for an operation without implementation")
+	}
+}
+
+func TestDefaultWorkflowsCycle(t *testing.T) {
+	fname := "./tests/invalids/test_dependency_cycle.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	if _, err := s.DefaultWorkflows(); err == nil {
+		t.Error("expected a cycle error")
+	} else if _, ok := err.(*CycleError); !ok {
+		t.Errorf("expected a *CycleError, got %v", err)
+	}
+}
@@ -0,0 +1,291 @@
+package toscalib
+
+import (
+	"fmt"
+	"sort"
+)
+
+// StepStatus is the execution status of a step of a WorkflowPlan
+type StepStatus int
+
+// The statuses a step goes through
+const (
+	StepPending   StepStatus = iota // The step waits for its predecessors.
+	StepRunning                     // The step has been started.
+	StepSucceeded                   // The step completed successfully.
+	StepFailed                      // The step failed.
+	StepSkipped                     // The step will never run as its predecessors did not trigger it.
+)
+
+func (s StepStatus) String() string {
+	switch s {
+	case StepPending:
+		return "pending"
+	case StepRunning:
+		return "running"
+	case StepSucceeded:
+		return "succeeded"
+	case StepFailed:
+		return "failed"
+	case StepSkipped:
+		return "skipped"
+	}
+	return fmt.Sprintf("StepStatus(%d)", int(s))
+}
+
+// WorkflowPlan is a workflow compiled into a directed acyclic graph of steps,
+// tracking the status of each step to give the steps that can run.
+// A step is triggered once all the steps having it in their on_success completed
+// successfully, or as soon as one of the steps having it in their on_failure
+// failed. The steps without predecessors are triggered at once. The steps that can
+// no longer be triggered are skipped.
+// A WorkflowPlan is not safe for concurrent use.
+type WorkflowPlan struct {
+	Name  string                    // The name of the workflow.
+	Steps map[string]StepDefinition // The steps of the workflow.
+	Order []string                  // The steps in a topological order.
+
+	onSuccess map[string][]string // the steps having the step in their on_success
+	onFailure map[string][]string // the steps having the step in their on_failure
+	status    map[string]StepStatus
+}
+
+// CompileWorkflow validates the named workflow of the topology and compiles it into
+// a WorkflowPlan. The deploy and undeploy workflows are generated (see DefaultWorkflows)
+// when the topology does not define them.
+// The ValidationErrors of the workflow are returned when it is not valid.
+func (s *ServiceTemplateDefinition) CompileWorkflow(name string) (*WorkflowPlan, error) {
+	wf, ok := s.TopologyTemplate.Workflows[name]
+	if !ok {
+		if name != DeployWorkflow && name != UndeployWorkflow {
+			return nil, fmt.Errorf("Workflow %q not found", name)
+		}
+		wfs, err := s.DefaultWorkflows()
+		if err != nil {
+			return nil, err
+		}
+		wf = wfs[name]
+	}
+
+	v := &validator{std: s, ft: flattenHierarchy(*s)}
+	v.validateWorkflow(name, wf)
+	if v.errs.HasErrors() {
+		sort.Stable(byPath(v.errs))
+		return nil, v.errs
+	}
+	return newWorkflowPlan(name, wf)
+}
+
+func newWorkflowPlan(name string, wf WorkflowDefinition) (*WorkflowPlan, error) {
+	if cycle := wf.findCycle(); cycle != nil {
+		return nil, fmt.Errorf("Workflow %q has a steps cycle: %v", name, cycle)
+	}
+
+	p := &WorkflowPlan{
+		Name:      name,
+		Steps:     wf.Steps,
+		onSuccess: make(map[string][]string),
+		onFailure: make(map[string][]string),
+		status:    make(map[string]StepStatus, len(wf.Steps)),
+	}
+	for _, sname := range sortedKeys(wf.Steps) {
+		for _, next := range wf.Steps[sname].OnSuccess {
+			if _, ok := wf.Steps[next]; !ok {
+				return nil, fmt.Errorf("Step %q of workflow %q references unknown step %q", sname, name, next)
+			}
+			p.onSuccess[next] = append(p.onSuccess[next], sname)
+		}
+		for _, next := range wf.Steps[sname].OnFailure {
+			if _, ok := wf.Steps[next]; !ok {
+				return nil, fmt.Errorf("Step %q of workflow %q references unknown step %q", sname, name, next)
+			}
+			p.onFailure[next] = append(p.onFailure[next], sname)
+		}
+	}
+
+	// order the steps by levels, the steps of a level by name
+	remaining := make(map[string]int, len(wf.Steps))
+	for sname := range wf.Steps {
+		remaining[sname] = len(p.onSuccess[sname]) + len(p.onFailure[sname])
+	}
+	for len(remaining) > 0 {
+		var level []string
+		for _, sname := range sortedKeys(remaining) {
+			if remaining[sname] == 0 {
+				level = append(level, sname)
+			}
+		}
+		for _, sname := range level {
+			delete(remaining, sname)
+			for _, next := range wf.Steps[sname].next() {
+				remaining[next]--
+			}
+		}
+		p.Order = append(p.Order, level...)
+	}
+	return p, nil
+}
+
+// next returns the steps following the step, on success and on failure
+func (s StepDefinition) next() []string {
+	return append(append([]string{}, s.OnSuccess...), s.OnFailure...)
+}
+
+// findCycle returns the first cycle found between the steps, following both
+// their on_success and on_failure, or nil
+func (wf WorkflowDefinition) findCycle() []string {
+	g := &DependencyGraph{Nodes: sortedKeys(wf.Steps)}
+	for _, sname := range g.Nodes {
+		for _, next := range wf.Steps[sname].next() {
+			if _, ok := wf.Steps[next]; ok {
+				g.Edges = append(g.Edges, DependencyEdge{Source: sname, Target: next})
+			}
+		}
+	}
+	return g.FindCycle()
+}
+
+// inlineCycle returns the chain of workflows leading back to the workflow from
+// one it inlines, or nil
+func (s *ServiceTemplateDefinition) inlineCycle(workflow, inline string) []string {
+	visited := make(map[string]bool)
+	var walk func(chain []string) []string
+	walk = func(chain []string) []string {
+		name := chain[len(chain)-1]
+		if name == workflow {
+			return chain
+		}
+		if visited[name] {
+			return nil
+		}
+		visited[name] = true
+		wf := s.TopologyTemplate.Workflows[name]
+		for _, sname := range sortedKeys(wf.Steps) {
+			for _, act := range wf.Steps[sname].Activities {
+				if act.Inline == "" {
+					continue
+				}
+				if cycle := walk(append(chain[:len(chain):len(chain)], act.Inline)); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		return nil
+	}
+	return walk([]string{workflow, inline})
+}
+
+// Status returns the status of the named step
+func (p *WorkflowPlan) Status(step string) StepStatus {
+	return p.status[step]
+}
+
+// Runnable returns the pending steps that are triggered, in the plan order
+func (p *WorkflowPlan) Runnable() []string {
+	var steps []string
+	for _, sname := range p.Order {
+		if p.status[sname] == StepPending && p.triggered(sname) {
+			steps = append(steps, sname)
+		}
+	}
+	return steps
+}
+
+// Start marks a runnable step as running
+func (p *WorkflowPlan) Start(step string) error {
+	if _, ok := p.Steps[step]; !ok {
+		return fmt.Errorf("Step %q not found", step)
+	}
+	if p.status[step] != StepPending || !p.triggered(step) {
+		return fmt.Errorf("Step %q is not runnable (%s)", step, p.status[step])
+	}
+	p.status[step] = StepRunning
+	return nil
+}
+
+// Succeed marks a running step as succeeded
+func (p *WorkflowPlan) Succeed(step string) error {
+	return p.complete(step, StepSucceeded)
+}
+
+// Fail marks a running step as failed, its on_failure steps are triggered
+func (p *WorkflowPlan) Fail(step string) error {
+	return p.complete(step, StepFailed)
+}
+
+func (p *WorkflowPlan) complete(step string, status StepStatus) error {
+	if p.status[step] != StepRunning {
+		return fmt.Errorf("Step %q is not running (%s)", step, p.status[step])
+	}
+	p.status[step] = status
+
+	// skip the steps that can't be triggered anymore, the order of the plan
+	// ensures the predecessors are always updated first.
+	for _, sname := range p.Order {
+		if p.status[sname] == StepPending && !p.triggered(sname) && !p.triggerable(sname) {
+			p.status[sname] = StepSkipped
+		}
+	}
+	return nil
+}
+
+// Done reports whether every step has completed or been skipped
+func (p *WorkflowPlan) Done() bool {
+	for _, sname := range p.Order {
+		if st := p.status[sname]; st == StepPending || st == StepRunning {
+			return false
+		}
+	}
+	return true
+}
+
+// Failed returns the steps that failed, in the plan order
+func (p *WorkflowPlan) Failed() []string {
+	var steps []string
+	for _, sname := range p.Order {
+		if p.status[sname] == StepFailed {
+			steps = append(steps, sname)
+		}
+	}
+	return steps
+}
+
+func (p *WorkflowPlan) triggered(step string) bool {
+	preds, failPreds := p.onSuccess[step], p.onFailure[step]
+	if len(preds) == 0 && len(failPreds) == 0 {
+		return true
+	}
+	for _, pred := range failPreds {
+		if p.status[pred] == StepFailed {
+			return true
+		}
+	}
+	if len(preds) == 0 {
+		return false
+	}
+	for _, pred := range preds {
+		if p.status[pred] != StepSucceeded {
+			return false
+		}
+	}
+	return true
+}
+
+// triggerable reports whether the step may still be triggered by its predecessors
+func (p *WorkflowPlan) triggerable(step string) bool {
+	for _, pred := range p.onFailure[step] {
+		if st := p.status[pred]; st == StepPending || st == StepRunning {
+			return true
+		}
+	}
+	preds := p.onSuccess[step]
+	if len(preds) == 0 {
+		return false
+	}
+	for _, pred := range preds {
+		if st := p.status[pred]; st == StepFailed || st == StepSkipped {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+package toscalib
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateWorkflows(t *testing.T) {
+	fname := "./tests/invalids/test_workflow_errors.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	errs := s.Validate()
+	want := []string{
+		"topology_template.workflows.broken.steps.unknown_operation.activities[0].set_state",
+		"topology_template.workflows.broken.steps.unknown_operation.activities[1].call_operation",
+		"topology_template.workflows.broken.steps.unknown_operation.activities[2].inline",
+		"topology_template.workflows.broken.steps.unknown_operation.on_success[0]",
+		"topology_template.workflows.broken.steps.unknown_requirement.target_relationship",
+		"topology_template.workflows.broken.steps.unknown_target.target",
+		"topology_template.workflows.inlined.steps.run.activities[0].inline",
+		"topology_template.workflows.inlining.steps.run.activities[0].inline",
+		"topology_template.workflows.looping.steps",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, actual %d:\n%v", len(want), len(errs), errs)
+	}
+	for i, path := range want {
+		if errs[i].Path != path {
+			t.Errorf("expected error on %s, actual %v", path, errs[i])
+		}
+	}
+
+	if _, err := s.CompileWorkflow("looping"); err == nil {
+		t.Error("expected the compilation of a cyclic workflow to fail")
+	}
+	if _, err := s.CompileWorkflow("inlining"); err == nil || !strings.Contains(err.Error(), "inlining -> inlined -> inlining") {
+		t.Errorf("expected the compilation of an inline cycle to fail, got %v", err)
+	}
+	if _, err := s.CompileWorkflow("missing"); err == nil {
+		t.Error("expected the compilation of an unknown workflow to fail")
+	}
+}
+
+func TestCompileWorkflow(t *testing.T) {
+	fname := "./tests/tosca_workflows.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	if errs := s.Validate(); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	p, err := s.CompileWorkflow("install_web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"install", "start", "cleanup", "notify"}; !reflect.DeepEqual(p.Order, expected) {
+		t.Errorf("expected order %v, actual %v", expected, p.Order)
+	}
+	if runnable := p.Runnable(); !reflect.DeepEqual(runnable, []string{"install"}) {
+		t.Fatalf("unexpected runnable steps %v", runnable)
+	}
+	if err := p.Start("start"); err == nil {
+		t.Error("expected a step waiting for its predecessors not to start")
+	}
+
+	p.Start("install")
+	if err := p.Succeed("install"); err != nil {
+		t.Fatal(err)
+	}
+	if runnable := p.Runnable(); !reflect.DeepEqual(runnable, []string{"start"}) {
+		t.Fatalf("unexpected runnable steps %v", runnable)
+	}
+	p.Start("start")
+	if err := p.Fail("start"); err != nil {
+		t.Fatal(err)
+	}
+	if p.Status("notify") != StepSkipped {
+		t.Errorf("expected notify to be skipped, actual %s", p.Status("notify"))
+	}
+	if runnable := p.Runnable(); !reflect.DeepEqual(runnable, []string{"cleanup"}) {
+		t.Fatalf("unexpected runnable steps %v", runnable)
+	}
+	p.Start("cleanup")
+	p.Succeed("cleanup")
+	if !p.Done() {
+		t.Error("expected the plan to be done")
+	}
+	if failed := p.Failed(); !reflect.DeepEqual(failed, []string{"start"}) {
+		t.Errorf("unexpected failed steps %v", failed)
+	}
+
+	// the plan of a successful run skips the failure handling
+	p, _ = s.CompileWorkflow("install_web")
+	for !p.Done() {
+		for _, step := range p.Runnable() {
+			p.Start(step)
+			p.Succeed(step)
+		}
+	}
+	if p.Status("cleanup") != StepSkipped || p.Status("notify") != StepSucceeded {
+		t.Errorf("unexpected statuses cleanup: %s, notify: %s", p.Status("cleanup"), p.Status("notify"))
+	}
+}
+
+func TestCompileDefaultWorkflows(t *testing.T) {
+	fname := "./tests/tosca_single_instance_wordpress.yaml"
+	var s ServiceTemplateDefinition
+	o, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Parse(o)
+	if err != nil {
+		t.Log("Error in processing", fname)
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{DeployWorkflow, UndeployWorkflow} {
+		p, err := s.CompileWorkflow(name)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if len(p.Order) != len(p.Steps) {
+			t.Errorf("%s: expected %d ordered steps, actual %v", name, len(p.Steps), p.Order)
+		}
+	}
+}